@@ -0,0 +1,245 @@
+package garden_acceptance_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-acceptance/gardentest"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/gomega/gbytes"
+)
+
+// capability is something a backend may or may not support. Specs that need
+// one call requireCapabilities, which skips them with the reason the probe
+// gave, so the suite runs cleanly against backends other than garden-linux.
+// Capabilities named in GARDEN_ACCEPTANCE_REQUIRED_CAPABILITIES fail those
+// specs instead, so that a regression in a backend that should have them
+// does not pass as skips.
+type capability string
+
+const (
+	privilegedContainers  capability = "privileged containers"
+	dockerRootFS          capability = "docker image rootfses"
+	fuse                  capability = "FUSE in privileged containers"
+	diskQuotas            capability = "disk quotas"
+	ipv6                  capability = "IPv6"
+	hostSyslog            capability = "a readable syslog on the host"
+	containerPathOnHost   capability = "container directories on the host"
+	hostNetworkNamespaces capability = "network namespaces listed on the host"
+	hostRouting           capability = "root network setup on the host"
+	gardenRestart         capability = "restarting Garden with monit"
+	capcheck              capability = "capcheck in the default rootfs"
+)
+
+// capabilityNames are how GARDEN_ACCEPTANCE_REQUIRED_CAPABILITIES refers to
+// capabilities; "all" requires every one.
+var capabilityNames = map[string]capability{
+	"privileged":     privilegedContainers,
+	"docker":         dockerRootFS,
+	"fuse":           fuse,
+	"disk-quotas":    diskQuotas,
+	"ipv6":           ipv6,
+	"host-syslog":    hostSyslog,
+	"container-path": containerPathOnHost,
+	"host-netns":     hostNetworkNamespaces,
+	"host-routing":   hostRouting,
+	"restart":        gardenRestart,
+	"capcheck":       capcheck,
+}
+
+// capabilityProbes return nil if the backend has the capability, or why not.
+var capabilityProbes = []struct {
+	capability capability
+	probe      func() error
+}{
+	{privilegedContainers, func() error {
+		return probeInContainer(garden.ContainerSpec{Privileged: true}, "true")
+	}},
+	{dockerRootFS, func() error {
+		return probeInContainer(garden.ContainerSpec{RootFSPath: "docker:///onsi/grace-busybox"}, "true")
+	}},
+	{fuse, func() error {
+		return probeInContainer(
+			garden.ContainerSpec{Privileged: true, RootFSPath: "/var/vcap/packages/rootfs/fusefs"},
+			"test -c /dev/fuse && which hellofs fusermount",
+		)
+	}},
+	{diskQuotas, func() error {
+		return probeInContainer(
+			garden.ContainerSpec{Limits: garden.Limits{
+				Disk: garden.DiskLimits{ByteHard: 1024 * 1024, Scope: garden.DiskLimitScopeExclusive},
+			}},
+			"if dd if=/dev/zero of=/tmp/quota-probe bs=1M count=2; then echo wrote 2MB past a 1MB quota; exit 1; fi",
+		)
+	}},
+	{ipv6, probeIPv6},
+	{hostSyslog, func() error {
+		if _, _, err := gardentest.RunCommand("sudo test -r /var/log/syslog"); err != nil {
+			return errors.New("cannot read /var/log/syslog")
+		}
+		return nil
+	}},
+	{containerPathOnHost, func() error {
+		container, err := gardenClient.Create(garden.ContainerSpec{})
+		if err != nil {
+			return err
+		}
+		defer gardenClient.Destroy(container.Handle())
+
+		info, err := container.Info()
+		if err != nil {
+			return err
+		}
+		_, err = os.Stat(filepath.Join(info.ContainerPath, "run", "wshd.pid"))
+		return err
+	}},
+	{hostNetworkNamespaces, func() error {
+		if _, stderr, err := gardentest.RunCommand("ip netns list"); err != nil {
+			return fmt.Errorf("ip netns list: %s", strings.TrimSpace(stderr))
+		}
+		return nil
+	}},
+	{hostRouting, func() error {
+		if _, stderr, err := gardentest.RunCommand("sudo -n ip netns add garden-acceptance-probe && sudo -n ip netns delete garden-acceptance-probe"); err != nil {
+			return fmt.Errorf("cannot add a network namespace: %s", strings.TrimSpace(stderr))
+		}
+		return nil
+	}},
+	{gardenRestart, func() error {
+		if _, _, err := gardentest.RunCommand("sudo -n /var/vcap/bosh/bin/monit summary | grep -q garden"); err != nil {
+			return errors.New("no garden job under monit")
+		}
+		return nil
+	}},
+	{capcheck, func() error {
+		return probeInContainer(garden.ContainerSpec{}, "test -x /bin/capcheck")
+	}},
+}
+
+// backendCapabilities holds the result of each probe, which runs once in
+// BeforeSuite.
+var backendCapabilities map[capability]error
+
+// requiredCapabilities are the ones GARDEN_ACCEPTANCE_REQUIRED_CAPABILITIES
+// names.
+var requiredCapabilities map[capability]bool
+
+func probeCapabilities() {
+	var err error
+	requiredCapabilities, err = parseRequiredCapabilities(os.Getenv("GARDEN_ACCEPTANCE_REQUIRED_CAPABILITIES"))
+	if err != nil {
+		Fail(err.Error())
+	}
+
+	backendCapabilities = map[capability]error{}
+	for _, p := range capabilityProbes {
+		err := p.probe()
+		backendCapabilities[p.capability] = err
+
+		if err == nil {
+			fmt.Fprintf(GinkgoWriter, "backend supports %s\n", p.capability)
+		} else {
+			fmt.Fprintf(GinkgoWriter, "backend lacks %s: %s\n", p.capability, err)
+		}
+	}
+}
+
+// parseRequiredCapabilities parses a comma-separated list of
+// capabilityNames.
+func parseRequiredCapabilities(list string) (map[capability]bool, error) {
+	required := map[capability]bool{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+		case name == "all":
+			for _, c := range capabilityNames {
+				required[c] = true
+			}
+		default:
+			c, ok := capabilityNames[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown capability %q in GARDEN_ACCEPTANCE_REQUIRED_CAPABILITIES", name)
+			}
+			required[c] = true
+		}
+	}
+	return required, nil
+}
+
+// requireCapabilities skips the current spec unless the backend has every
+// one of capabilities, or fails it if a missing one is required.
+func requireCapabilities(capabilities ...capability) {
+	for _, c := range capabilities {
+		err, probed := backendCapabilities[c]
+		if !probed {
+			Fail(fmt.Sprintf("no probe for capability %q", c))
+		}
+		if err != nil && requiredCapabilities[c] {
+			Fail(fmt.Sprintf("backend lacks required %s: %s", c, err))
+		}
+		if err != nil {
+			Skip(fmt.Sprintf("backend lacks %s: %s", c, err))
+		}
+	}
+}
+
+// probeInContainer runs script as root in a new container created from spec
+// and reports why if either fails.
+func probeInContainer(spec garden.ContainerSpec, script string) error {
+	container, err := gardenClient.Create(spec)
+	if err != nil {
+		return err
+	}
+	defer gardenClient.Destroy(container.Handle())
+
+	output := gbytes.NewBuffer()
+	process, err := container.Run(garden.ProcessSpec{User: "root", Path: "sh", Args: []string{"-c", script}}, recordedProcessIO(output))
+	if err != nil {
+		return err
+	}
+
+	status, err := process.Wait()
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return fmt.Errorf("%q exited with %d: %s", script, status, strings.TrimSpace(string(output.Contents())))
+	}
+	return nil
+}
+
+// hostIPv6 is the host's global IPv6 address, found by the IPv6 probe.
+var hostIPv6 net.IP
+
+// probeIPv6 requires both the host and a freshly created container to have a
+// global IPv6 address.
+func probeIPv6() error {
+	hostIPv6 = hostGlobalIPv6()
+	if hostIPv6 == nil {
+		return fmt.Errorf("no global IPv6 address on the interface holding %s", hostIP)
+	}
+
+	container, err := gardenClient.Create(withProbes(garden.ContainerSpec{}))
+	if err != nil {
+		return err
+	}
+	defer gardenClient.Destroy(container.Handle())
+
+	info, err := probeNetInfo(container)
+	if err != nil {
+		return fmt.Errorf("netinfo: %s", err)
+	}
+	for _, iface := range info.Interfaces {
+		if len(iface.GlobalIPv6()) > 0 {
+			return nil
+		}
+	}
+	return errors.New("containers are not given a global IPv6 address")
+}
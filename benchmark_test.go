@@ -0,0 +1,231 @@
+package garden_acceptance_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-acceptance/gardentest"
+	"github.com/cloudfoundry-incubator/garden/client"
+	"github.com/cloudfoundry-incubator/garden/client/connection"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// The benchmarks only run when GARDEN_ACCEPTANCE_BENCHMARK is set, e.g.
+//
+//	GARDEN_ACCEPTANCE_BENCHMARK=1 \
+//	GARDEN_ACCEPTANCE_BENCHMARK_CONCURRENCY=4 \
+//	GARDEN_ACCEPTANCE_BENCHMARK_BASELINE=previous.json \
+//	ginkgo -focus=benchmarks
+//
+// Concurrency should not exceed the port pool size, as every worker holds a
+// NetIn port while its container exists. The benchmarks talk to Garden
+// directly rather than through gardenClient, whose fault proxy and recorder
+// would otherwise be part of every measurement.
+var _ = Describe("benchmarks", func() {
+	BeforeEach(func() {
+		if os.Getenv("GARDEN_ACCEPTANCE_BENCHMARK") == "" {
+			Skip("set GARDEN_ACCEPTANCE_BENCHMARK to run benchmarks")
+		}
+	})
+
+	It("measures the latency of core operations", func() {
+		concurrency := envInt("GARDEN_ACCEPTANCE_BENCHMARK_CONCURRENCY", 4)
+		iterations := envInt("GARDEN_ACCEPTANCE_BENCHMARK_ITERATIONS", 20)
+		output := gardentest.EnvOrDefault("GARDEN_ACCEPTANCE_BENCHMARK_OUTPUT", "benchmark.json")
+		threshold := envInt("GARDEN_ACCEPTANCE_BENCHMARK_THRESHOLD", 20)
+
+		directClient := client.New(connection.New("tcp", gardenAddress))
+		recorder := newLatencyRecorder()
+		var wg sync.WaitGroup
+		for worker := 0; worker < concurrency; worker++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				for i := 0; i < iterations; i++ {
+					benchmarkIteration(directClient, recorder)
+				}
+			}()
+		}
+		wg.Wait()
+
+		result := benchmarkResult{
+			Concurrency: concurrency,
+			Iterations:  iterations,
+			Operations:  recorder.summarize(),
+		}
+
+		encoded, err := json.MarshalIndent(result, "", "  ")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(ioutil.WriteFile(output, encoded, 0644)).Should(Succeed())
+		fmt.Fprintf(GinkgoWriter, "benchmark results written to %s\n%s\n", output, encoded)
+
+		baselinePath := os.Getenv("GARDEN_ACCEPTANCE_BENCHMARK_BASELINE")
+		if baselinePath == "" {
+			return
+		}
+
+		encodedBaseline, err := ioutil.ReadFile(baselinePath)
+		Ω(err).ShouldNot(HaveOccurred())
+		var baseline benchmarkResult
+		Ω(json.Unmarshal(encodedBaseline, &baseline)).Should(Succeed())
+		Ω([]int{result.Concurrency, result.Iterations}).Should(Equal([]int{baseline.Concurrency, baseline.Iterations}), fmt.Sprintf(
+			"%s was recorded with concurrency %d and %d iterations, and this run used %d and %d, so their latencies are not comparable",
+			baselinePath, baseline.Concurrency, baseline.Iterations, result.Concurrency, result.Iterations,
+		))
+
+		Ω(result.regressionsFrom(baseline, float64(threshold))).Should(BeEmpty(),
+			fmt.Sprintf("latency regressed by more than %d%% compared to %s", threshold, baselinePath))
+	})
+})
+
+func benchmarkIteration(direct garden.Client, recorder *latencyRecorder) {
+	var container garden.Container
+	recorder.time("Create", func() (err error) {
+		container, err = direct.Create(garden.ContainerSpec{})
+		return err
+	})
+
+	recorder.time("Run", func() error {
+		process, err := container.Run(garden.ProcessSpec{User: "root", Path: "true"}, silentProcessIO)
+		if err != nil {
+			return err
+		}
+		status, err := process.Wait()
+		if err == nil && status != 0 {
+			err = fmt.Errorf("exited with %d", status)
+		}
+		return err
+	})
+
+	recorder.time("NetIn", func() error {
+		_, _, err := container.NetIn(0, 0)
+		return err
+	})
+
+	tar := tarFile("benchmark", bytes.Repeat([]byte("x"), 64*1024))
+	recorder.time("StreamIn", func() error {
+		return container.StreamIn(garden.StreamInSpec{Path: "/tmp", User: "root", TarFile: tar})
+	})
+
+	handles := []string{container.Handle()}
+	recorder.time("BulkInfo", func() error {
+		_, err := direct.BulkInfo(handles)
+		return err
+	})
+
+	recorder.time("BulkMetrics", func() error {
+		_, err := direct.BulkMetrics(handles)
+		return err
+	})
+
+	recorder.time("Destroy", func() error {
+		return direct.Destroy(container.Handle())
+	})
+}
+
+type latencyRecorder struct {
+	mutex   sync.Mutex
+	samples map[string][]time.Duration
+}
+
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{samples: map[string][]time.Duration{}}
+}
+
+func (r *latencyRecorder) time(operation string, f func() error) {
+	start := time.Now()
+	err := f()
+	elapsed := time.Since(start)
+	Ω(err).ShouldNot(HaveOccurred(), fmt.Sprintf("Error during %s", operation))
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.samples[operation] = append(r.samples[operation], elapsed)
+}
+
+func (r *latencyRecorder) summarize() map[string]latencySummary {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	summaries := map[string]latencySummary{}
+	for operation, samples := range r.samples {
+		sorted := append([]time.Duration{}, samples...)
+		sort.Sort(durations(sorted))
+		summaries[operation] = latencySummary{
+			Samples: len(sorted),
+			P50:     milliseconds(percentile(sorted, 50)),
+			P95:     milliseconds(percentile(sorted, 95)),
+			P99:     milliseconds(percentile(sorted, 99)),
+		}
+	}
+	return summaries
+}
+
+type benchmarkResult struct {
+	Concurrency int                       `json:"concurrency"`
+	Iterations  int                       `json:"iterations"`
+	Operations  map[string]latencySummary `json:"operations"`
+}
+
+type latencySummary struct {
+	Samples int     `json:"samples"`
+	P50     float64 `json:"p50_ms"`
+	P95     float64 `json:"p95_ms"`
+	P99     float64 `json:"p99_ms"`
+}
+
+// regressionsFrom compares p50 and p95 against a baseline; p99 is recorded
+// but too noisy at these sample sizes to gate on.
+func (result benchmarkResult) regressionsFrom(baseline benchmarkResult, thresholdPercent float64) []string {
+	var regressions []string
+	check := func(operation, name string, current, previous float64) {
+		if previous > 0 && current > previous*(1+thresholdPercent/100) {
+			regressions = append(regressions, fmt.Sprintf("%s %s: %.1fms -> %.1fms", operation, name, previous, current))
+		}
+	}
+
+	for operation, previous := range baseline.Operations {
+		current, ok := result.Operations[operation]
+		if !ok {
+			continue
+		}
+		check(operation, "p50", current.P50, previous.P50)
+		check(operation, "p95", current.P95, previous.P95)
+	}
+	sort.Strings(regressions)
+	return regressions
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// percentile uses the nearest-rank method on sorted samples.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
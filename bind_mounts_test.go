@@ -5,7 +5,6 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	"github.com/onsi/gomega/gbytes"
 )
 
 var _ = Describe("bind_mounts", func() {
@@ -20,44 +19,20 @@ var _ = Describe("bind_mounts", func() {
 			},
 		})
 
-		buffer := gbytes.NewBuffer()
-		process, err := container.Run(garden.ProcessSpec{
-			User: "root",
-			Path: "ls",
-			Args: []string{"/home/alice/bindmount/readonly"},
-		}, recordedProcessIO(buffer))
-		Ω(err).ShouldNot(HaveOccurred())
-		Ω(process.Wait()).Should(Equal(0))
-		Ω(buffer).Should(gbytes.Say("rootfs"))
+		result := runIn(container).Command("ls", "/home/alice/bindmount/readonly").Wait()
+		Ω(result).Should(ExitWith(0))
+		Ω(result).Should(HaveStdout(ContainSubstring("rootfs")))
 
-		process, err = container.Run(garden.ProcessSpec{
-			User: "root",
-			Path: "touch",
-			Args: []string{"/home/alice/bindmount/readonly/new_file"},
-		}, silentProcessIO)
-		Ω(err).ShouldNot(HaveOccurred())
-		Ω(process.Wait()).ShouldNot(Equal(0))
+		Ω(runIn(container).Command("touch", "/home/alice/bindmount/readonly/new_file").Wait()).ShouldNot(ExitWith(0))
 
-		buffer = gbytes.NewBuffer()
-		process, err = container.Run(garden.ProcessSpec{
-			User: "root",
-			Path: "ls",
-			Args: []string{"/home/alice/bindmount/readonly"},
-		}, recordedProcessIO(buffer))
-		Ω(err).ShouldNot(HaveOccurred())
-		Ω(process.Wait()).Should(Equal(0))
-		Ω(buffer).ShouldNot(gbytes.Say("new_file"))
+		result = runIn(container).Command("ls", "/home/alice/bindmount/readonly").Wait()
+		Ω(result).Should(ExitWith(0))
+		Ω(result).ShouldNot(HaveStdout(ContainSubstring("new_file")))
 
-		buffer = gbytes.NewBuffer()
-		process, err = container.Run(garden.ProcessSpec{
-			User: "root",
-			Path: "ls",
-			Args: []string{"-l", "/home/alice"},
-		}, recordedProcessIO(buffer))
-		Ω(err).ShouldNot(HaveOccurred())
-		Ω(process.Wait()).Should(Equal(0))
-		Ω(buffer).ShouldNot(gbytes.Say("65534"))
-		Ω(buffer).ShouldNot(gbytes.Say("nobody"))
+		result = runIn(container).Command("ls", "-l", "/home/alice").Wait()
+		Ω(result).Should(ExitWith(0))
+		Ω(result).ShouldNot(HaveStdout(ContainSubstring("65534")))
+		Ω(result).ShouldNot(HaveStdout(ContainSubstring("nobody")))
 	})
 
 	It("can mount a read/write BindMount (#75464648)", func() {
@@ -72,33 +47,14 @@ var _ = Describe("bind_mounts", func() {
 			},
 		})
 
-		buffer := gbytes.NewBuffer()
-		process, err := container.Run(garden.ProcessSpec{
-			User: "root",
-			Path: "ls",
-			Args: []string{"/home/alice/readwrite"},
-		}, recordedProcessIO(buffer))
-		Ω(err).ShouldNot(HaveOccurred())
-		Ω(process.Wait()).Should(Equal(0))
-		Ω(buffer).ShouldNot(gbytes.Say("new_file"))
+		result := runIn(container).Command("ls", "/home/alice/readwrite").Wait()
+		Ω(result).Should(ExitWith(0))
+		Ω(result).ShouldNot(HaveStdout(ContainSubstring("new_file")))
 
-		buffer = gbytes.NewBuffer()
-		process, err = container.Run(garden.ProcessSpec{
-			User: "root",
-			Path: "touch",
-			Args: []string{"/home/alice/readwrite/new_file"},
-		}, recordedProcessIO(buffer))
-		Ω(err).ShouldNot(HaveOccurred())
-		Ω(process.Wait()).Should(Equal(0))
+		Ω(runIn(container).Command("touch", "/home/alice/readwrite/new_file").Wait()).Should(ExitWith(0))
 
-		buffer = gbytes.NewBuffer()
-		process, err = container.Run(garden.ProcessSpec{
-			User: "root",
-			Path: "ls",
-			Args: []string{"/home/alice/readwrite"},
-		}, recordedProcessIO(buffer))
-		Ω(err).ShouldNot(HaveOccurred())
-		Ω(process.Wait()).Should(Equal(0))
-		Ω(buffer).Should(gbytes.Say("new_file"))
+		result = runIn(container).Command("ls", "/home/alice/readwrite").Wait()
+		Ω(result).Should(ExitWith(0))
+		Ω(result).Should(HaveStdout(ContainSubstring("new_file")))
 	})
 })
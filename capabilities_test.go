@@ -7,12 +7,16 @@ import (
 	"github.com/onsi/gomega/gbytes"
 )
 
-// TODO: Add capcheck to garden-acceptance bosh release, bind mount in to containers
-var _ = PDescribe("dropping capabilities", func() {
+var _ = Describe("dropping capabilities", func() {
 	var container garden.Container
 
+	BeforeEach(func() {
+		requireCapabilities(capcheck)
+	})
+
 	Context("for privileged containers", func() {
 		BeforeEach(func() {
+			requireCapabilities(privilegedContainers)
 			container = createContainer(gardenClient, garden.ContainerSpec{Privileged: true})
 		})
 
@@ -0,0 +1,111 @@
+// dnsstandin is a minimal UDP DNS server that answers every A query with the
+// same address. It prints "listening" once bound, then each name it is asked
+// for on a line of its own.
+//
+//	dnsstandin [-listen ADDRESS] [-answer IP]
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+var (
+	listen = flag.String("listen", ":53", "UDP address to serve on")
+	answer = flag.String("answer", "10.9.8.7", "address to answer A queries with")
+)
+
+func main() {
+	flag.Parse()
+
+	ip := net.ParseIP(*answer).To4()
+	if ip == nil {
+		fmt.Fprintln(os.Stderr, "dnsstandin: not an IPv4 address:", *answer)
+		os.Exit(1)
+	}
+
+	udpAddr, err := net.ResolveUDPAddr("udp4", *listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dnsstandin:", err)
+		os.Exit(1)
+	}
+
+	conn, err := net.ListenUDP("udp4", udpAddr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dnsstandin:", err)
+		os.Exit(1)
+	}
+	fmt.Println("listening")
+
+	packet := make([]byte, 512)
+	for {
+		n, from, err := conn.ReadFromUDP(packet)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "dnsstandin:", err)
+			os.Exit(1)
+		}
+
+		response, name, err := respond(packet[:n], ip)
+		if err != nil {
+			continue
+		}
+
+		fmt.Println(name)
+		conn.WriteToUDP(response, from)
+	}
+}
+
+func respond(query []byte, answer net.IP) ([]byte, string, error) {
+	const headerLength = 12
+	if len(query) < headerLength {
+		return nil, "", errors.New("short query")
+	}
+
+	var labels []string
+	offset := headerLength
+	for {
+		if offset >= len(query) {
+			return nil, "", errors.New("truncated question")
+		}
+		length := int(query[offset])
+		offset++
+		if length == 0 {
+			break
+		}
+		if offset+length > len(query) {
+			return nil, "", errors.New("truncated label")
+		}
+		labels = append(labels, string(query[offset:offset+length]))
+		offset += length
+	}
+	if offset+4 > len(query) {
+		return nil, "", errors.New("truncated question")
+	}
+	questionType := binary.BigEndian.Uint16(query[offset:])
+	question := query[headerLength : offset+4]
+
+	response := make([]byte, headerLength, 512)
+	copy(response, query[:2])
+	binary.BigEndian.PutUint16(response[2:], 0x8180) // response, recursion desired and available
+	binary.BigEndian.PutUint16(response[4:], 1)
+	response = append(response, question...)
+
+	if questionType == 1 {
+		binary.BigEndian.PutUint16(response[6:], 1)
+		response = append(response,
+			0xc0, headerLength, // pointer to the question name
+			0, 1, // type A
+			0, 1, // class IN
+			0, 0, 0, 60, // ttl
+			0, 4, // rdlength
+		)
+		response = append(response, answer...)
+	}
+
+	return response, strings.Join(labels, "."), nil
+}
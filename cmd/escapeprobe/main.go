@@ -0,0 +1,227 @@
+// escapeprobe tries known ways out of a container and prints, as JSON, which
+// of them were blocked. Each attempt is as harmless as it can be while still
+// needing the privilege it tests: files are opened for writing but not
+// written, the hostname is set to its current value, and anything that
+// succeeds is undone.
+//
+//	escapeprobe [-host-cmdline ARGS] [vector...]
+//
+// With no vectors it tries them all.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+type Attempt struct {
+	Vector  string `json:"vector"`
+	Blocked bool   `json:"blocked"`
+	Detail  string `json:"detail"`
+}
+
+type vector struct {
+	name string
+	try  func() Attempt
+}
+
+var hostCmdline = flag.String("host-cmdline", "", "command line, space separated, of a host process to try to ptrace")
+
+var vectors = []vector{
+	{"proc-sys-write", procSysWrite},
+	{"sys-write", sysWrite},
+	{"mknod-block-device", mknodBlockDevice},
+	{"mount", mount},
+	{"ptrace-host-process", ptraceHostProcess},
+	{"load-kernel-module", loadKernelModule},
+	{"sethostname", sethostname},
+	{"raw-socket", rawSocket},
+}
+
+func main() {
+	flag.Parse()
+
+	wanted := map[string]bool{}
+	for _, name := range flag.Args() {
+		wanted[name] = true
+	}
+
+	attempts := []Attempt{}
+	for _, v := range vectors {
+		if len(wanted) > 0 && !wanted[v.name] {
+			continue
+		}
+		delete(wanted, v.name)
+
+		attempt := v.try()
+		attempt.Vector = v.name
+		attempts = append(attempts, attempt)
+	}
+
+	for name := range wanted {
+		fmt.Fprintf(os.Stderr, "escapeprobe: unknown vector %q\n", name)
+		os.Exit(2)
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(attempts); err != nil {
+		fmt.Fprintln(os.Stderr, "escapeprobe:", err)
+		os.Exit(1)
+	}
+}
+
+func blocked(format string, args ...interface{}) Attempt {
+	return Attempt{Blocked: true, Detail: fmt.Sprintf(format, args...)}
+}
+
+func escaped(format string, args ...interface{}) Attempt {
+	return Attempt{Blocked: false, Detail: fmt.Sprintf(format, args...)}
+}
+
+// openForWrite opens the first of paths that exists for writing, without
+// writing to it.
+func openForWrite(paths ...string) Attempt {
+	for _, path := range paths {
+		file, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return blocked("%s", err)
+		}
+		file.Close()
+		return escaped("opened %s for writing", path)
+	}
+	return blocked("none of %v exist", paths)
+}
+
+func procSysWrite() Attempt {
+	return openForWrite("/proc/sys/kernel/core_pattern", "/proc/sys/vm/drop_caches")
+}
+
+func sysWrite() Attempt {
+	return openForWrite("/sys/kernel/uevent_helper", "/sys/kernel/mm/transparent_hugepage/enabled", "/sys/power/state")
+}
+
+// mknodBlockDevice makes a node for the host's first disk. The device cgroup
+// may still stop it being opened, which counts as blocked.
+func mknodBlockDevice() Attempt {
+	dir, err := ioutil.TempDir("", "escapeprobe")
+	if err != nil {
+		return blocked("cannot make a directory to try in: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sda")
+	if err := syscall.Mknod(path, syscall.S_IFBLK|0600, 8<<8|0); err != nil {
+		return blocked("mknod: %s", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return blocked("mknod succeeded but open failed: %s", err)
+	}
+	file.Close()
+	return escaped("opened block device 8:0 at %s", path)
+}
+
+func mount() Attempt {
+	dir, err := ioutil.TempDir("", "escapeprobe")
+	if err != nil {
+		return blocked("cannot make a directory to try in: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := syscall.Mount("escapeprobe", dir, "tmpfs", 0, ""); err != nil {
+		return blocked("mount tmpfs: %s", err)
+	}
+	syscall.Unmount(dir, 0)
+	return escaped("mounted tmpfs on %s", dir)
+}
+
+// ptraceHostProcess looks for the host process running -host-cmdline and
+// attaches to it. It goes by command line rather than PID because a host PID
+// means nothing in the container's PID namespace; the process should not be
+// visible there at all.
+func ptraceHostProcess() Attempt {
+	if *hostCmdline == "" {
+		return blocked("no -host-cmdline given")
+	}
+
+	pid, err := findProcess(*hostCmdline)
+	if err != nil {
+		return blocked("%s", err)
+	}
+
+	// every ptrace request must come from the thread that attached
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := syscall.PtraceAttach(pid); err != nil {
+		return blocked("%q is PID %d here, but ptrace attach: %s", *hostCmdline, pid, err)
+	}
+	var status syscall.WaitStatus
+	syscall.Wait4(pid, &status, 0, nil)
+	syscall.PtraceDetach(pid)
+	return escaped("attached to %q, PID %d here", *hostCmdline, pid)
+}
+
+// findProcess returns the PID, in this PID namespace, of the process whose
+// arguments joined by spaces are cmdline.
+func findProcess(cmdline string) (int, error) {
+	paths, err := filepath.Glob("/proc/[0-9]*/cmdline")
+	if err != nil {
+		return 0, err
+	}
+	for _, path := range paths {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		args := strings.Split(strings.TrimRight(string(contents), "\x00"), "\x00")
+		if strings.Join(args, " ") == cmdline {
+			return strconv.Atoi(filepath.Base(filepath.Dir(path)))
+		}
+	}
+	return 0, fmt.Errorf("no process running %q is visible", cmdline)
+}
+
+// loadKernelModule calls init_module with an empty image. The kernel checks
+// CAP_SYS_MODULE before looking at the image, so any error but EPERM means
+// the check passed.
+func loadKernelModule() Attempt {
+	params, _ := syscall.BytePtrFromString("")
+	_, _, errno := syscall.Syscall(syscall.SYS_INIT_MODULE, 0, 0, uintptr(unsafe.Pointer(params)))
+	if errno == syscall.EPERM {
+		return blocked("init_module: %s", errno)
+	}
+	return escaped("init_module got past the capability check: %s", errno)
+}
+
+func sethostname() Attempt {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return blocked("cannot read hostname: %s", err)
+	}
+	if err := syscall.Sethostname([]byte(hostname)); err != nil {
+		return blocked("sethostname: %s", err)
+	}
+	return escaped("set hostname to %q", hostname)
+}
+
+func rawSocket() Attempt {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_RAW)
+	if err != nil {
+		return blocked("socket: %s", err)
+	}
+	syscall.Close(fd)
+	return escaped("opened a raw IP socket")
+}
@@ -0,0 +1,85 @@
+// garden-replay reproduces the Garden API exchange in a recording written by
+// the acceptance suite when a spec fails.
+//
+// By default it serves the recording from a Garden server whose backend
+// answers from the recording, then re-issues the recorded calls against it,
+// which exercises the client and server wire protocol with the exact traffic
+// of the failure. With -target it re-issues the calls against a real server
+// instead, and with -serve it only serves the recording so that another
+// client can be pointed at it.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cloudfoundry-incubator/garden-acceptance/recorder"
+	"github.com/cloudfoundry-incubator/garden/client/connection"
+	"github.com/cloudfoundry-incubator/garden/server"
+	"github.com/pivotal-golang/lager"
+)
+
+var recordingPath = flag.String("recording", "", "recording to replay")
+var target = flag.String("target", "", "address of a Garden server to replay against instead of the recording")
+var listenAddress = flag.String("listen", "127.0.0.1:7778", "address to serve the recording on")
+var serveOnly = flag.Bool("serve", false, "serve the recording until interrupted instead of replaying it")
+
+func main() {
+	flag.Parse()
+	if *recordingPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	os.Exit(replay())
+}
+
+func replay() int {
+	file, err := os.Open(*recordingPath)
+	if err != nil {
+		fail(err)
+	}
+	events, err := recorder.Load(file)
+	file.Close()
+	if err != nil {
+		fail(fmt.Errorf("loading %s: %s", *recordingPath, err))
+	}
+
+	address := *target
+	if address == "" {
+		address = *listenAddress
+
+		logger := lager.NewLogger("garden-replay")
+		logger.RegisterSink(lager.NewWriterSink(os.Stderr, lager.ERROR))
+
+		backend := recorder.NewBackend(events)
+		gardenServer := server.New("tcp", address, 0, backend, logger)
+		if err := gardenServer.Start(); err != nil {
+			fail(err)
+		}
+		defer gardenServer.Stop()
+		backend.Serving()
+
+		if *serveOnly {
+			fmt.Printf("serving %d events from %s on %s\n", len(events), *recordingPath, address)
+			select {}
+		}
+	}
+
+	mismatches := recorder.Drive(connection.New("tcp", address), events)
+	for _, mismatch := range mismatches {
+		fmt.Println(mismatch)
+	}
+	fmt.Printf("replayed %d events against %s: %d mismatches\n", len(events), address, len(mismatches))
+
+	if len(mismatches) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "garden-replay:", err)
+	os.Exit(1)
+}
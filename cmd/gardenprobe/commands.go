@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-acceptance/gardentest"
+	"github.com/cloudfoundry-incubator/garden/client"
+	"github.com/cloudfoundry-incubator/garden/client/connection"
+)
+
+// target holds the flags every subcommand shares.
+type target struct {
+	address string
+	handle  string
+}
+
+func newFlagSet(name string) (*flag.FlagSet, *target) {
+	t := &target{}
+	flags := flag.NewFlagSet("gardenprobe "+name, flag.ExitOnError)
+	flags.StringVar(&t.address, "target", gardentest.EnvOrDefault("GARDEN_ADDRESS", "10.244.16.6:7777"), "Garden server address (default from GARDEN_ADDRESS)")
+	flags.StringVar(&t.handle, "handle", "gardenprobe", "container handle")
+	return flags, t
+}
+
+func (t *target) client() client.Client {
+	return client.New(connection.New("tcp", t.address))
+}
+
+// recreate destroys any container left over with the target handle and
+// creates a new one.
+func (t *target) recreate(gardenClient client.Client, spec garden.ContainerSpec) (garden.Container, error) {
+	gardenClient.Destroy(t.handle)
+
+	spec.Handle = t.handle
+	container, err := gardenClient.Create(spec)
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %s", t.handle, err)
+	}
+	return container, nil
+}
+
+func create(args []string) error {
+	flags, t := newFlagSet("create")
+	rootfs := flags.String("rootfs", "", "rootfs path or docker:// URL")
+	privileged := flags.Bool("privileged", false, "create a privileged container")
+	network := flags.String("network", "", "subnet or IP, e.g. 10.2.0.0/24")
+	var env stringList
+	flags.Var(&env, "env", "NAME=VALUE for the container's environment (repeatable)")
+	flags.Parse(args)
+
+	container, err := t.recreate(t.client(), garden.ContainerSpec{
+		RootFSPath: *rootfs,
+		Privileged: *privileged,
+		Network:    *network,
+		Env:        env,
+	})
+	if err != nil {
+		return err
+	}
+
+	info, err := container.Info()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created %s with IP %s at %s\n", container.Handle(), info.ContainerIP, info.ContainerPath)
+	return nil
+}
+
+func run(args []string) error {
+	flags, t := newFlagSet("run")
+	user := flags.String("user", "root", "user to run as")
+	dir := flags.String("dir", "", "working directory")
+	var env stringList
+	flags.Var(&env, "env", "NAME=VALUE for the process's environment (repeatable)")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no command given; usage: gardenprobe run [flags] -- <path> [args...]")
+	}
+
+	container, err := t.client().Lookup(t.handle)
+	if err != nil {
+		return err
+	}
+
+	process, err := container.Run(garden.ProcessSpec{
+		User: *user,
+		Dir:  *dir,
+		Env:  env,
+		Path: flags.Arg(0),
+		Args: flags.Args()[1:],
+	}, garden.ProcessIO{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr})
+	if err != nil {
+		return err
+	}
+
+	status, err := process.Wait()
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return exitStatus(status)
+	}
+	return nil
+}
+
+func locale(args []string) error {
+	flags, t := newFlagSet("locale")
+	rootfs := flags.String("rootfs", "docker:///debian#8", "rootfs path or docker:// URL")
+	lang := flags.String("lang", "en_GB.iso885915", "LANG to give the container")
+	flags.Parse(args)
+
+	container, err := t.recreate(t.client(), garden.ContainerSpec{
+		RootFSPath: *rootfs,
+		Env:        []string{"LANG=" + *lang},
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := gardentest.Run(container, garden.ProcessSpec{
+		User: "root",
+		Path: "sh",
+		Args: []string{"-c", "echo $LANG; locale"},
+	}, nil, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	firstLine := strings.SplitN(string(result.Stdout), "\n", 2)[0]
+	if result.ExitCode != 0 || firstLine != *lang {
+		return fmt.Errorf("expected LANG %q, process exited %d with LANG %q", *lang, result.ExitCode, firstLine)
+	}
+	fmt.Printf("LANG %s reaches processes in %s\n", *lang, container.Handle())
+	return nil
+}
+
+func netOut(args []string) error {
+	flags, t := newFlagSet("netout")
+	protocol := flags.String("protocol", "icmp", "icmp, tcp or udp")
+	network := flags.String("network", "8.8.8.8", "IP, range (a-b) or CIDR to allow")
+	port := flags.Uint("port", 53, "port to allow and check, for tcp and udp")
+	query := flags.String("query", "google.com", "name to look up, for udp, which checks with a DNS query")
+	restart := flags.Bool("restart", false, "restart Garden after adding the rule and check it survives (on the Garden host only)")
+	flags.Parse(args)
+
+	ipRange, err := gardentest.ParseIPRange(*network)
+	if err != nil {
+		return err
+	}
+	destination := ipRange.Start.String()
+
+	var rule garden.NetOutRule
+	var check []string
+	switch *protocol {
+	case "icmp":
+		rule = gardentest.PingRule(destination)
+		check = []string{"ping", "-c", "1", "-w", "3", destination}
+	case "tcp":
+		rule = gardentest.TCPRule(destination, uint16(*port))
+		check = []string{"nc", "-z", "-w", "3", destination, strconv.Itoa(int(*port))}
+	case "udp":
+		// nc cannot tell a dropped datagram from one nobody answered, so
+		// UDP is checked with a query that needs a reply
+		if *port != 53 {
+			return fmt.Errorf("udp is checked with a DNS query, so -port must be 53, not %d", *port)
+		}
+		rule = gardentest.UDPRule(destination, uint16(*port))
+		check = []string{"nslookup", *query, destination}
+	default:
+		return fmt.Errorf("unknown protocol %q", *protocol)
+	}
+	rule.Networks = []garden.IPRange{ipRange}
+
+	gardenClient := t.client()
+	container, err := t.recreate(gardenClient, garden.ContainerSpec{})
+	if err != nil {
+		return err
+	}
+	if err := container.NetOut(rule); err != nil {
+		return fmt.Errorf("NetOut: %s", err)
+	}
+
+	if *restart {
+		if err := gardentest.RestartGarden(gardenClient, 60*time.Second); err != nil {
+			return err
+		}
+		if container, err = gardenClient.Lookup(t.handle); err != nil {
+			return fmt.Errorf("after restart: %s", err)
+		}
+	}
+
+	result, err := gardentest.Run(container, garden.ProcessSpec{User: "root", Path: check[0], Args: check[1:]}, nil, os.Stdout)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%s exited %d: %s traffic to %s is not getting out", strings.Join(check, " "), result.ExitCode, *protocol, destination)
+	}
+	fmt.Printf("%s traffic to %s gets out of %s\n", *protocol, destination, container.Handle())
+	return nil
+}
+
+func restartCheck(args []string) error {
+	flags, t := newFlagSet("restart-check")
+	timeout := flags.Duration("timeout", 60*time.Second, "how long to wait for Garden to come back")
+	flags.Parse(args)
+
+	gardenClient := t.client()
+	container, err := t.recreate(gardenClient, garden.ContainerSpec{
+		Properties: garden.Properties{"gardenprobe": "restart-check"},
+	})
+	if err != nil {
+		return err
+	}
+
+	hostPort, containerPort, err := container.NetIn(0, 0)
+	if err != nil {
+		return fmt.Errorf("NetIn: %s", err)
+	}
+	before, err := container.Info()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("restarting garden")
+	if err := gardentest.RestartGarden(gardenClient, *timeout); err != nil {
+		return err
+	}
+
+	container, err = gardenClient.Lookup(t.handle)
+	if err != nil {
+		return fmt.Errorf("container lost in restart: %s", err)
+	}
+
+	after, err := container.Info()
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	if after.ContainerIP != before.ContainerIP {
+		problems = append(problems, fmt.Sprintf("IP changed from %s to %s", before.ContainerIP, after.ContainerIP))
+	}
+	if after.Properties["gardenprobe"] != "restart-check" {
+		problems = append(problems, fmt.Sprintf("properties are now %v", after.Properties))
+	}
+	if !hasMapping(after.MappedPorts, hostPort, containerPort) {
+		problems = append(problems, fmt.Sprintf("port mapping %d->%d lost: %v", hostPort, containerPort, after.MappedPorts))
+	}
+
+	result, err := gardentest.Run(container, garden.ProcessSpec{User: "root", Path: "echo", Args: []string{"alive"}}, nil, nil)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("cannot run processes: %s", err))
+	} else if result.ExitCode != 0 || !bytes.Equal(result.Stdout, []byte("alive\n")) {
+		problems = append(problems, fmt.Sprintf("echo exited %d with %q", result.ExitCode, result.Stdout))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s did not survive the restart:\n  %s", t.handle, strings.Join(problems, "\n  "))
+	}
+	fmt.Printf("%s survived the restart\n", t.handle)
+	return nil
+}
+
+func hasMapping(mappings []garden.PortMapping, hostPort, containerPort uint32) bool {
+	for _, mapping := range mappings {
+		if mapping.HostPort == hostPort && mapping.ContainerPort == containerPort {
+			return true
+		}
+	}
+	return false
+}
+
+func cleanup(args []string) error {
+	flags, t := newFlagSet("cleanup")
+	all := flags.Bool("all", false, "destroy every container, not just -handle")
+	flags.Parse(args)
+
+	gardenClient := t.client()
+	if *all {
+		return gardentest.DestroyAll(gardenClient)
+	}
+	return gardenClient.Destroy(t.handle)
+}
@@ -0,0 +1,91 @@
+// gardenprobe reproduces acceptance scenarios by hand against a Garden
+// server:
+//
+//	gardenprobe create -handle foo -rootfs docker:///debian#8
+//	gardenprobe run -handle foo -- sh -c 'echo $LANG'
+//	gardenprobe locale -lang en_GB.iso885915
+//	gardenprobe netout -protocol icmp -network 8.8.8.8 -restart
+//	gardenprobe restart-check
+//	gardenprobe cleanup -all
+//
+// Every subcommand takes -target and -handle. Subcommands that restart
+// Garden must run on the Garden host.
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+type command struct {
+	description string
+	run         func(args []string) error
+}
+
+var commands = map[string]command{
+	"create":        {"create a container", create},
+	"run":           {"run a process in a container and exit with its status", run},
+	"locale":        {"check that a container's LANG reaches its processes", locale},
+	"netout":        {"allow outbound traffic from a container and check it flows", netOut},
+	"restart-check": {"check that a container survives a Garden restart", restartCheck},
+	"cleanup":       {"destroy a container, or all of them", cleanup},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "gardenprobe: unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		if status, ok := err.(exitStatus); ok {
+			os.Exit(int(status))
+		}
+		fmt.Fprintf(os.Stderr, "gardenprobe %s: %s\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gardenprobe <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", name, commands[name].description)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run gardenprobe <command> -h for its flags.")
+}
+
+// exitStatus makes gardenprobe exit with a process's status without printing
+// an error.
+type exitStatus int
+
+func (s exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(s))
+}
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/cloudfoundry-incubator/garden-acceptance/netinfo"
+)
+
+func main() {
+	info, err := netinfo.Collect()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "netinfo:", err)
+		os.Exit(1)
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(info); err != nil {
+		fmt.Fprintln(os.Stderr, "netinfo:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,184 @@
+// syscallprobe makes a curated list of dangerous syscalls and prints, as a
+// JSON object, how each one fared: "allowed" if it got past the permission
+// and filter checks, or the errno (EPERM, EACCES or ENOSYS) that stopped it.
+//
+// Arguments are chosen so that a call either fails on them after the
+// permission check, or succeeds harmlessly and is undone: modules and kexec
+// images are empty, paths do not exist, and the hostname is set to itself.
+// Syscall numbers are for linux/amd64.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	sysSyslog          = 103
+	sysMknod           = 133
+	sysVhangup         = 153
+	sysPivotRoot       = 155
+	sysChroot          = 161
+	sysAcct            = 163
+	sysMount           = 165
+	sysUmount2         = 166
+	sysSwapon          = 167
+	sysSwapoff         = 168
+	sysReboot          = 169
+	sysSethostname     = 170
+	sysSetdomainname   = 171
+	sysIopl            = 172
+	sysIoperm          = 173
+	sysInitModule      = 175
+	sysDeleteModule    = 176
+	sysNfsservctl      = 180
+	sysKexecLoad       = 246
+	sysKeyctl          = 250
+	sysUnshare         = 272
+	sysOpenByHandleAt  = 304
+	sysFinitModule     = 313
+	sysBPF             = 321
+	sysUserfaultfd     = 323
+	syslogSizeBuffer   = 10
+	keyctlGetKeyringID = 0
+	keySpecSessionRing = ^uintptr(2) // -3
+	kexecInvalidFlags  = 0xffff0000
+)
+
+// scratch is an empty directory for syscalls that need a real path.
+var scratch string
+
+var probes = map[string]func() syscall.Errno{
+	"acct":              func() syscall.Errno { return call(sysAcct, str(filepath.Join(scratch, "missing"))) },
+	"bpf":               func() syscall.Errno { return call(sysBPF, 0, 0, 0) },
+	"chroot":            func() syscall.Errno { return call(sysChroot, str("/")) },
+	"delete_module":     func() syscall.Errno { return call(sysDeleteModule, str("syscallprobe_missing"), 0) },
+	"finit_module":      func() syscall.Errno { return call(sysFinitModule, ^uintptr(0), str(""), 0) },
+	"init_module":       func() syscall.Errno { return call(sysInitModule, 0, 0, str("")) },
+	"ioperm":            func() syscall.Errno { return call(sysIoperm, 0x80, 1, 1) },
+	"iopl":              func() syscall.Errno { return call(sysIopl, 3) },
+	"kexec_load":        func() syscall.Errno { return call(sysKexecLoad, 0, 0, 0, kexecInvalidFlags) },
+	"keyctl":            func() syscall.Errno { return call(sysKeyctl, keyctlGetKeyringID, keySpecSessionRing, 0) },
+	"mknod":             mknodBlockDevice,
+	"mount":             mount,
+	"nfsservctl":        func() syscall.Errno { return call(sysNfsservctl, 0, 0, 0) },
+	"open_by_handle_at": func() syscall.Errno { return call(sysOpenByHandleAt, ^uintptr(0), 0, 0) },
+	"pivot_root":        func() syscall.Errno { return call(sysPivotRoot, str(scratch), str(scratch)) },
+	"reboot":            func() syscall.Errno { return call(sysReboot, 0, 0, 0, 0) },
+	"setdomainname":     func() syscall.Errno { return setname(sysSetdomainname, "/proc/sys/kernel/domainname") },
+	"sethostname":       func() syscall.Errno { return setname(sysSethostname, "/proc/sys/kernel/hostname") },
+	"swapoff":           func() syscall.Errno { return call(sysSwapoff, str(filepath.Join(scratch, "missing"))) },
+	"swapon":            func() syscall.Errno { return call(sysSwapon, str(filepath.Join(scratch, "missing")), 0) },
+	"syslog":            func() syscall.Errno { return call(sysSyslog, syslogSizeBuffer, 0, 0) },
+	"umount2":           func() syscall.Errno { return call(sysUmount2, str(scratch), 0) },
+	"unshare":           func() syscall.Errno { return call(sysUnshare, syscall.CLONE_NEWUTS) },
+	"userfaultfd":       userfaultfd,
+	"vhangup":           func() syscall.Errno { return call(sysVhangup) },
+}
+
+func main() {
+	var err error
+	scratch, err = ioutil.TempDir("", "syscallprobe")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "syscallprobe:", err)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(scratch)
+
+	results := map[string]string{}
+	for name, probe := range probes {
+		results[name] = classify(probe())
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(results); err != nil {
+		fmt.Fprintln(os.Stderr, "syscallprobe:", err)
+		os.Exit(1)
+	}
+}
+
+// classify treats any errno but the ones permission checks and filters
+// return as the kernel getting as far as looking at the arguments.
+func classify(errno syscall.Errno) string {
+	switch errno {
+	case syscall.EPERM:
+		return "EPERM"
+	case syscall.EACCES:
+		return "EACCES"
+	case syscall.ENOSYS:
+		return "ENOSYS"
+	default:
+		return "allowed"
+	}
+}
+
+// call makes a raw syscall. Each argument is an integer, or a *byte from
+// str, which is kept alive until the syscall returns so that the kernel never
+// reads freed memory.
+func call(number uintptr, args ...interface{}) syscall.Errno {
+	var a [6]uintptr
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case *byte:
+			a[i] = uintptr(unsafe.Pointer(arg))
+		case uintptr:
+			a[i] = arg
+		case int:
+			a[i] = uintptr(arg)
+		default:
+			panic(fmt.Sprintf("unsupported syscall argument %T", arg))
+		}
+	}
+	_, _, errno := syscall.Syscall6(number, a[0], a[1], a[2], a[3], a[4], a[5])
+	runtime.KeepAlive(args)
+	return errno
+}
+
+func str(s string) *byte {
+	p, err := syscall.BytePtrFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// setname sets the hostname or domain name to its current value.
+func setname(number uintptr, current string) syscall.Errno {
+	name, err := ioutil.ReadFile(current)
+	if err != nil {
+		panic(err)
+	}
+	if len(name) > 0 && name[len(name)-1] == '\n' {
+		name = name[:len(name)-1]
+	}
+	name = append(name, 0)
+	return call(number, &name[0], len(name)-1)
+}
+
+func mknodBlockDevice() syscall.Errno {
+	path := filepath.Join(scratch, "sda")
+	errno := call(sysMknod, str(path), syscall.S_IFBLK|0600, 8<<8|0)
+	os.Remove(path)
+	return errno
+}
+
+func mount() syscall.Errno {
+	errno := call(sysMount, str("syscallprobe"), str(scratch), str("tmpfs"), 0, str(""))
+	if errno == 0 {
+		syscall.Unmount(scratch, 0)
+	}
+	return errno
+}
+
+func userfaultfd() syscall.Errno {
+	fd, _, errno := syscall.Syscall(sysUserfaultfd, syscall.O_CLOEXEC, 0, 0)
+	if errno == 0 {
+		syscall.Close(int(fd))
+	}
+	return errno
+}
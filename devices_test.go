@@ -0,0 +1,160 @@
+package garden_acceptance_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("device nodes", func() {
+	rootFSes := []struct {
+		name         string
+		path         string
+		capabilities []capability
+	}{
+		{"the default rootfs", "", nil},
+		{"the fusefs rootfs", "/var/vcap/packages/rootfs/fusefs", nil},
+		{"a docker rootfs", "docker:///debian#8", []capability{dockerRootFS}},
+	}
+
+	// hostDevices are character devices that would let a container read host
+	// memory or the kernel log. The device cgroup should refuse them even to
+	// a container that can make the node.
+	hostDevices := []struct {
+		path         string
+		major, minor int
+	}{
+		{"/dev/mem", 1, 1},
+		{"/dev/kmem", 1, 2},
+		{"/dev/port", 1, 4},
+		{"/dev/kmsg", 1, 11},
+	}
+
+	for _, rootFS := range rootFSes {
+		for _, privileged := range []bool{false, true} {
+			rootFS, privileged := rootFS, privileged
+
+			kind := "unprivileged"
+			if privileged {
+				kind = "privileged"
+			}
+
+			Context(fmt.Sprintf("in %s containers with %s", kind, rootFS.name), func() {
+				var container garden.Container
+
+				BeforeEach(func() {
+					requireCapabilities(rootFS.capabilities...)
+					if privileged {
+						requireCapabilities(privilegedContainers)
+					}
+					container = createContainer(gardenClient, garden.ContainerSpec{RootFSPath: rootFS.path, Privileged: privileged})
+				})
+
+				It("has the standard devices and no host ones", func() {
+					result := runIn(container).Command("ls", "/dev").Wait()
+					Ω(result).Should(ExitWith(0))
+					devices := strings.Fields(string(result.Stdout.Contents()))
+					fmt.Fprintf(GinkgoWriter, "/dev: %s\n", strings.Join(devices, " "))
+
+					Ω(devices).Should(ContainElement("null"))
+					Ω(devices).Should(ContainElement("zero"))
+					Ω(devices).Should(ContainElement("urandom"))
+					Ω(devices).Should(ContainElement("tty"))
+					Ω(devices).Should(ContainElement("shm"))
+					for _, device := range hostDevices {
+						Ω(devices).ShouldNot(ContainElement(strings.TrimPrefix(device.path, "/dev/")))
+					}
+
+					Ω(runIn(container).Command("find", "/dev", "-type", "b").Wait()).Should(HaveStdout(BeEmpty()))
+				})
+
+				// Privileged containers can make device nodes, so the
+				// device cgroup is what stops them reading host devices.
+				// Unprivileged ones cannot make the nodes at all.
+				refusesDevice := func(node, kind string, major, minor int) {
+					mknod := runIn(container).Command("mknod", node, kind, strconv.Itoa(major), strconv.Itoa(minor)).Wait()
+					if !privileged {
+						Ω(mknod).ShouldNot(ExitWith(0), "made %s", node)
+						Ω(mknod).Should(HaveStderr(ContainSubstring("Operation not permitted")))
+						return
+					}
+					Ω(mknod).Should(ExitWith(0), "could not make %s", node)
+
+					read := runIn(container).Command("head", "-c", "1", node).Wait()
+					Ω(read).ShouldNot(ExitWith(0), "read %s", node)
+					Ω(read).Should(HaveStderr(ContainSubstring("Operation not permitted")))
+				}
+
+				It("refuses host devices", func() {
+					for _, device := range hostDevices {
+						refusesDevice("/tmp/"+filepath.Base(device.path), "c", device.major, device.minor)
+					}
+				})
+
+				It("refuses block devices", func() {
+					refusesDevice("/tmp/sda", "b", 8, 0)
+				})
+
+				It("reads nothing from /dev/null and discards writes to it", func() {
+					Ω(runIn(container).Shell("echo lost > /dev/null && head -c 1 /dev/null | wc -c").Wait()).
+						Should(HaveStdout(MatchRegexp(`^\s*0\n$`)))
+				})
+
+				It("reads zeros from /dev/zero", func() {
+					result := runIn(container).Command("head", "-c", "4", "/dev/zero").Wait()
+					Ω(result).Should(ExitWith(0))
+					Ω(result.Stdout.Contents()).Should(Equal([]byte{0, 0, 0, 0}))
+				})
+
+				It("reads different random bytes from /dev/urandom each time", func() {
+					read := func() []byte {
+						result := runIn(container).Command("head", "-c", "16", "/dev/urandom").Wait()
+						Ω(result).Should(ExitWith(0))
+						Ω(result.Stdout.Contents()).Should(HaveLen(16))
+						return result.Stdout.Contents()
+					}
+					Ω(read()).ShouldNot(Equal(read()))
+				})
+
+				It("opens /dev/tty only for processes with a terminal", func() {
+					Ω(runIn(container).Shell("echo hello > /dev/tty").Wait()).ShouldNot(ExitWith(0))
+
+					result := runIn(container).TTY().Shell("echo hello > /dev/tty").Wait()
+					Ω(result).Should(ExitWith(0))
+					Ω(result).Should(HaveStdout(ContainSubstring("hello")))
+				})
+
+				It("has a world-writable, sticky /dev/shm", func() {
+					Ω(runIn(container).Command("stat", "-c", "%a", "/dev/shm").Wait()).Should(HaveStdout("1777\n"))
+					Ω(runIn(container).Command("touch", "/dev/shm/written").Wait()).Should(ExitWith(0))
+				})
+			})
+		}
+	}
+
+	Describe("/dev/fuse", func() {
+		It("is present in privileged containers", func() {
+			requireCapabilities(privilegedContainers)
+			container := createContainer(gardenClient, garden.ContainerSpec{Privileged: true})
+			Ω(runIn(container).Command("test", "-c", "/dev/fuse").Wait()).Should(ExitWith(0))
+		})
+
+		It("is absent from unprivileged containers", func() {
+			container := createContainer(gardenClient, garden.ContainerSpec{})
+			Ω(runIn(container).Command("test", "-e", "/dev/fuse").Wait()).Should(ExitWith(1))
+		})
+
+		It("cannot be mounted in unprivileged containers, for want of the device", func() {
+			container := createContainer(gardenClient, garden.ContainerSpec{RootFSPath: "/var/vcap/packages/rootfs/fusefs"})
+			result := runIn(container).Shell("mkdir -p /tmp/fuse-test && /usr/bin/hellofs /tmp/fuse-test").Wait()
+			Ω(result).ShouldNot(ExitWith(0))
+			Ω(result).Should(HaveStderr(ContainSubstring("fuse: device not found")))
+		})
+	})
+})
@@ -8,6 +8,10 @@ import (
 )
 
 var _ = Describe("disk quotas", func() {
+	BeforeEach(func() {
+		requireCapabilities(diskQuotas)
+	})
+
 	verifyQuotasAcrossUsers := func(rootfs string) {
 		rootfsBytesUsed := rootFSDiskUsage(rootfs)
 		var byteLimit uint64 = rootfsBytesUsed + (1024 * 1024 * 2)
@@ -57,6 +61,10 @@ var _ = Describe("disk quotas", func() {
 	Context("when the container is created from a docker image (#92647640)", func() {
 		rootfs := "docker:///cloudfoundry/garden-pm#alice"
 
+		BeforeEach(func() {
+			requireCapabilities(dockerRootFS)
+		})
+
 		It("sets a single quota for the whole container", func() {
 			verifyQuotasAcrossUsers(rootfs)
 		})
@@ -0,0 +1,153 @@
+package garden_acceptance_test
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-acceptance/gardentest"
+	"github.com/cloudfoundry-incubator/garden-acceptance/netinfo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("DNS", func() {
+	rootfses := []struct{ description, path string }{
+		{"a directory rootfs", ""},
+		{"a docker image", "docker:///onsi/grace-busybox"},
+	}
+
+	for _, rootfs := range rootfses {
+		rootfs := rootfs
+
+		Context("for a container created from "+rootfs.description, func() {
+			var container garden.Container
+
+			BeforeEach(func() {
+				if strings.HasPrefix(rootfs.path, "docker://") {
+					requireCapabilities(dockerRootFS)
+				}
+				container = createContainer(gardenClient, withProbes(garden.ContainerSpec{RootFSPath: rootfs.path}))
+			})
+
+			It("uses the host's resolvers", func() {
+				hostResolvConf, err := os.Open("/etc/resolv.conf")
+				Ω(err).ShouldNot(HaveOccurred())
+				defer hostResolvConf.Close()
+
+				hostDNS, err := netinfo.ParseResolvConf(hostResolvConf)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(containerNetInfo(container).DNS.Nameservers).Should(Equal(hostDNS.Nameservers))
+			})
+
+			It("has a hostname matching its handle", func() {
+				Ω(containerNetInfo(container).Hostname).Should(Equal(container.Handle()))
+			})
+
+			It("lists its handle in /etc/hosts", func() {
+				buffer := gbytes.NewBuffer()
+				process, err := container.Run(garden.ProcessSpec{User: "root", Path: "cat", Args: []string{"/etc/hosts"}}, recordedProcessIO(buffer))
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(process.Wait()).Should(Equal(0))
+
+				var hostnames []string
+				for _, line := range strings.Split(string(buffer.Contents()), "\n") {
+					if fields := strings.Fields(line); len(fields) > 1 && !strings.HasPrefix(fields[0], "#") {
+						hostnames = append(hostnames, fields[1:]...)
+					}
+				}
+				Ω(hostnames).Should(ContainElement(container.Handle()))
+			})
+		})
+	}
+
+	// NetOut only governs traffic that leaves the container network, so the
+	// stand-in serves from a network namespace on the host that containers
+	// reach through their gateway, at an address outside every container
+	// subnet.
+	Describe("NetOut rules for port 53", func() {
+		const queriedName = "garden-acceptance.test"
+		const namespace = "garden-acceptance-dns"
+		const serverIP = "198.51.100.2"
+		var standIn *exec.Cmd
+		var served *gbytes.Buffer
+		var container garden.Container
+
+		BeforeEach(func() {
+			standIn = nil
+			requireCapabilities(hostRouting)
+
+			_, stderr, err := gardentest.RunCommand(strings.Join([]string{
+				"sudo ip netns add " + namespace,
+				"sudo ip link add gacc-dns type veth peer name gacc-dns-ns",
+				"sudo ip link set gacc-dns-ns netns " + namespace,
+				"sudo ip addr add 198.51.100.1/30 dev gacc-dns",
+				"sudo ip link set gacc-dns up",
+				"sudo ip netns exec " + namespace + " ip addr add " + serverIP + "/30 dev gacc-dns-ns",
+				"sudo ip netns exec " + namespace + " ip link set gacc-dns-ns up",
+				"sudo ip netns exec " + namespace + " ip route add default via 198.51.100.1",
+			}, " && "))
+			Ω(err).ShouldNot(HaveOccurred(), "setting up the DNS stand-in's namespace: "+stderr)
+
+			served = gbytes.NewBuffer()
+			standIn = exec.Command("sudo", "ip", "netns", "exec", namespace,
+				filepath.Join(probesDir, "dnsstandin"), "-listen", serverIP+":53", "-answer", "10.9.8.7")
+			standIn.Stdout = io.MultiWriter(served, GinkgoWriter)
+			standIn.Stderr = GinkgoWriter
+			Ω(standIn.Start()).Should(Succeed())
+			Eventually(served, "5s").Should(gbytes.Say("listening\n"), "DNS stand-in did not start")
+
+			container = createContainer(gardenClient, garden.ContainerSpec{})
+		})
+
+		AfterEach(func() {
+			// deleting the namespace also deletes both ends of the veth
+			gardentest.RunCommand("sudo ip netns pids " + namespace + " | sudo xargs -r kill; sudo ip netns delete " + namespace)
+			if standIn != nil {
+				standIn.Wait()
+			}
+		})
+
+		lookup := func() (int, *gbytes.Buffer) {
+			buffer := gbytes.NewBuffer()
+			process, err := container.Run(garden.ProcessSpec{
+				User: "root",
+				Path: "nslookup",
+				Args: []string{queriedName, serverIP},
+			}, recordedProcessIO(buffer))
+			Ω(err).ShouldNot(HaveOccurred())
+			status, err := process.Wait()
+			Ω(err).ShouldNot(HaveOccurred())
+			return status, buffer
+		}
+
+		It("blocks queries without a rule", func() {
+			status, _ := lookup()
+			Ω(status).ShouldNot(Equal(0))
+			Consistently(served).ShouldNot(gbytes.Say(queriedName))
+		})
+
+		It("blocks queries when only another UDP port is allowed", func() {
+			Ω(container.NetOut(gardentest.UDPRule(serverIP, 54))).Should(Succeed())
+
+			status, _ := lookup()
+			Ω(status).ShouldNot(Equal(0))
+			Consistently(served).ShouldNot(gbytes.Say(queriedName))
+		})
+
+		It("allows queries once UDP port 53 is allowed", func() {
+			Ω(container.NetOut(gardentest.UDPRule(serverIP, 53))).Should(Succeed())
+
+			status, buffer := lookup()
+			Ω(status).Should(Equal(0))
+			Ω(buffer).Should(gbytes.Say("10.9.8.7"))
+			Ω(served).Should(gbytes.Say(queriedName))
+		})
+	})
+})
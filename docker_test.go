@@ -9,6 +9,10 @@ import (
 )
 
 var _ = Describe("docker docker docker", func() {
+	BeforeEach(func() {
+		requireCapabilities(dockerRootFS)
+	})
+
 	PIt("returns a helpful error message when image not found from default registry (#89007566)", func() {
 		_, err := gardenClient.Create(garden.ContainerSpec{RootFSPath: "docker:///cloudfoundry/doesnotexist"})
 		Ω(err.Error()).Should(ContainSubstring("could not fetch image cloudfoundry/doesnotexist from registry index.docker.io: HTTP code: 404"))
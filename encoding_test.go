@@ -0,0 +1,174 @@
+package garden_acceptance_test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// overJSON is what arrives after s crosses the Garden API. Env values, args
+// and process IO travel as JSON strings, and encoding them replaces each byte
+// that is not part of valid UTF-8 with U+FFFD, before the backend sees it.
+func overJSON(s string) string {
+	encoded, err := json.Marshal(s)
+	Ω(err).ShouldNot(HaveOccurred())
+	var decoded string
+	Ω(json.Unmarshal(encoded, &decoded)).Should(Succeed())
+	return decoded
+}
+
+var _ = Describe("locales and encodings", func() {
+	// nonUTF8 is invalid UTF-8 (a stray continuation byte, a truncated
+	// sequence, bytes that never appear) mixed with ISO-8859-15 text. It has
+	// no NUL, which can't appear in env values or args. Every byte of it above
+	// 0x7f is invalid on its own, so overJSON replaces each one.
+	const nonUTF8 = "\xff\xfe caf\xe9 \xc3\x28 \xa9\x80 \xa4"
+
+	// hexOf has a process print stdin as hex, so that values can be checked
+	// without relying on the stdout they are also tested against.
+	const hexOf = "od -An -v -tx1 | tr -d ' \\n'"
+
+	Context("in a docker:///debian#8 container", func() {
+		var container garden.Container
+
+		BeforeEach(func() {
+			requireCapabilities(dockerRootFS)
+			container = createContainer(gardenClient, garden.ContainerSpec{
+				RootFSPath: "docker:///debian#8",
+				Env:        []string{"LANG=en_GB.iso885915"},
+			})
+		})
+
+		It("passes LANG from the container spec to processes", func() {
+			Ω(runIn(container).Shell("echo $LANG").Wait()).Should(HaveStdout("en_GB.iso885915\n"))
+		})
+
+		It("lets the process spec override the container's locale", func() {
+			result := runIn(container).Env("LANG=C.UTF-8", "LC_ALL=C").Shell("echo $LANG $LC_ALL").Wait()
+			Ω(result).Should(ExitWith(0))
+			Ω(result).Should(HaveStdout("C.UTF-8 C\n"))
+		})
+
+		It("replaces the ISO-8859-15 bytes of env values with U+FFFD", func() {
+			result := runIn(container).Env("GREETING=" + nonUTF8).Shell(`printf %s "$GREETING" | ` + hexOf).Wait()
+			Ω(result).Should(HaveStdout(hex.EncodeToString([]byte(overJSON(nonUTF8)))))
+		})
+	})
+
+	// These pin what the JSON encoding of the API does to bytes that are not
+	// UTF-8, so that a change in either direction shows up.
+	Describe("bytes that are not UTF-8", func() {
+		var container garden.Container
+
+		BeforeEach(func() {
+			container = createContainer(gardenClient, garden.ContainerSpec{
+				Env: []string{"CONTAINER_VALUE=" + nonUTF8},
+			})
+		})
+
+		It("replaces them in env values from the container spec", func() {
+			result := runIn(container).Shell(`printf %s "$CONTAINER_VALUE" | ` + hexOf).Wait()
+			Ω(result).Should(HaveStdout(hex.EncodeToString([]byte(overJSON(nonUTF8)))))
+		})
+
+		It("replaces them in env values from the process spec", func() {
+			result := runIn(container).Env("PROCESS_VALUE=" + nonUTF8).Shell(`printf %s "$PROCESS_VALUE" | ` + hexOf).Wait()
+			Ω(result).Should(HaveStdout(hex.EncodeToString([]byte(overJSON(nonUTF8)))))
+		})
+
+		It("replaces them in args", func() {
+			result := runIn(container).Command("sh", "-c", `printf %s "$1" | `+hexOf, "sh", nonUTF8).Wait()
+			Ω(result).Should(HaveStdout(hex.EncodeToString([]byte(overJSON(nonUTF8)))))
+		})
+
+		It("preserves values containing '=', newlines, quotes and nothing at all", func() {
+			values := []string{"a=b=c", "line one\nline two\n", `'"\$(pwd)` + "`id`", ""}
+
+			var env []string
+			var script []string
+			for i, value := range values {
+				env = append(env, fmt.Sprintf("VALUE_%d=%s", i, value))
+				script = append(script, fmt.Sprintf(`printf '%%s|' "$VALUE_%d"`, i))
+			}
+
+			result := runIn(container).Env(env...).Shell(strings.Join(script, "; ")).Wait()
+			Ω(result).Should(HaveStdout(strings.Join(values, "|") + "|"))
+		})
+
+		// In ascending order every byte above 0x7f is invalid on its own,
+		// so the result does not depend on where the stream is chunked.
+		It("streams ASCII on stdout and stderr and replaces every other byte", func() {
+			var all []byte
+			var escaped []string
+			for b := 0; b < 256; b++ {
+				all = append(all, byte(b))
+				escaped = append(escaped, fmt.Sprintf(`\%03o`, b))
+			}
+			printAll := "printf '" + strings.Join(escaped, "") + "'"
+
+			result := runIn(container).Shell(printAll + "; " + printAll + " >&2").Wait()
+			Ω(result).Should(ExitWith(0))
+			Ω(string(result.Stdout.Contents())).Should(Equal(overJSON(string(all))))
+			Ω(string(result.Stderr.Contents())).Should(Equal(overJSON(string(all))))
+		})
+
+		It("carries binary stdin to stdout with the same replacements", func() {
+			// each byte above 0x7f is followed by an ASCII one, so that no
+			// valid sequence can be split between chunks
+			r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+			input := make([]byte, 1024*1024)
+			for i := 0; i < len(input); i += 2 {
+				input[i] = byte(r.Intn(256))
+				input[i+1] = byte(r.Intn(128))
+			}
+
+			process, result := runIn(container).Command("cat").Stdin(input).Start()
+			Ω(process.Wait()).Should(Equal(0))
+			Ω(string(result.Stdout.Contents())).Should(Equal(overJSON(string(input))))
+		})
+	})
+
+	Describe("long env blocks", func() {
+		var container garden.Container
+
+		BeforeEach(func() {
+			container = createContainer(gardenClient, garden.ContainerSpec{})
+		})
+
+		It("passes a thousand variables", func() {
+			var env []string
+			for i := 0; i < 1000; i++ {
+				env = append(env, fmt.Sprintf("LONG_%d=%d", i, i))
+			}
+
+			result := runIn(container).Env(env...).Shell("env | grep -c '^LONG_'; echo $LONG_999").Wait()
+			Ω(result).Should(HaveStdout("1000\n999\n"))
+		})
+
+		It("passes a single 100KB value from the container and process specs", func() {
+			big := strings.Repeat("0123456789", 10*1024)
+			withBigEnv := createContainer(gardenClient, garden.ContainerSpec{Env: []string{"FROM_CONTAINER=" + big}})
+
+			result := runIn(withBigEnv).Env("FROM_PROCESS=" + big).
+				Shell(`printf %s "$FROM_CONTAINER" | md5sum; printf %s "$FROM_PROCESS" | md5sum`).Wait()
+			Ω(result).Should(ExitWith(0))
+
+			sum := fmt.Sprintf("%x  -\n", md5.Sum([]byte(big)))
+			Ω(result).Should(HaveStdout(sum + sum))
+		})
+
+		It("passes a 100KB argument", func() {
+			big := strings.Repeat("abcdefghij", 10*1024)
+			result := runIn(container).Command("sh", "-c", `printf %s "$1" | wc -c`, "sh", big).Wait()
+			Ω(result).Should(HaveStdout(MatchRegexp(`^\s*102400\n$`)))
+		})
+	})
+})
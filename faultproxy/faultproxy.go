@@ -0,0 +1,234 @@
+// Package faultproxy is a TCP proxy that injects network faults between a
+// Garden client and server: latency, connections dropped mid-stream,
+// truncated responses and resets of the hijacked connections that carry
+// process output.
+package faultproxy
+
+import (
+	"io"
+	"net"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Faults are applied to every proxied connection; the zero value forwards
+// traffic untouched. Byte counts and delays are per connection, and zero
+// disables a fault.
+type Faults struct {
+	// Latency delays every chunk forwarded in either direction.
+	Latency time.Duration
+
+	// DropAfterBytes closes both sides once this many bytes have been
+	// forwarded in either direction.
+	DropAfterBytes int64
+
+	// TruncateResponsesAfterBytes forwards only this many bytes from the
+	// server and then closes the client's side for writing, as if the server
+	// had finished early.
+	TruncateResponsesAfterBytes int64
+
+	// ResetProcessStreamsAfter resets, with a TCP RST, connections that run
+	// or attach to a process this long after the request is seen.
+	ResetProcessStreamsAfter time.Duration
+}
+
+// processRequest matches the requests for Run and Attach, whose connections
+// the server hijacks to stream process output.
+var processRequest = regexp.MustCompile(`^(POST|GET) /containers/[^/ ]+/processes`)
+
+type Proxy struct {
+	listener net.Listener
+	target   string
+
+	mutex  sync.Mutex
+	faults Faults
+	conns  map[*proxiedConn]struct{}
+}
+
+// New starts a proxy to target on an ephemeral local port.
+func New(target string) (*Proxy, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := &Proxy{listener: listener, target: target, conns: map[*proxiedConn]struct{}{}}
+	go proxy.serve()
+	return proxy, nil
+}
+
+func (p *Proxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+// SetFaults changes the faults for new connections. Open connections are
+// dropped, so that connections the client keeps alive between requests
+// cannot carry on under the old faults.
+func (p *Proxy) SetFaults(faults Faults) {
+	p.mutex.Lock()
+	p.faults = faults
+	p.mutex.Unlock()
+
+	p.DropAll()
+}
+
+// DropAll closes every open connection.
+func (p *Proxy) DropAll() {
+	for _, conn := range p.openConns() {
+		conn.close()
+	}
+}
+
+// ResetAll resets every open connection with a TCP RST.
+func (p *Proxy) ResetAll() {
+	for _, conn := range p.openConns() {
+		conn.reset()
+	}
+}
+
+func (p *Proxy) Close() error {
+	err := p.listener.Close()
+	p.DropAll()
+	return err
+}
+
+func (p *Proxy) openConns() []*proxiedConn {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var conns []*proxiedConn
+	for conn := range p.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+func (p *Proxy) serve() {
+	for {
+		client, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		server, err := net.Dial("tcp", p.target)
+		if err != nil {
+			client.Close()
+			continue
+		}
+
+		p.mutex.Lock()
+		conn := &proxiedConn{client: client.(*net.TCPConn), server: server.(*net.TCPConn), faults: p.faults}
+		p.conns[conn] = struct{}{}
+		p.mutex.Unlock()
+
+		go func() {
+			conn.run()
+
+			p.mutex.Lock()
+			delete(p.conns, conn)
+			p.mutex.Unlock()
+		}()
+	}
+}
+
+type proxiedConn struct {
+	client *net.TCPConn
+	server *net.TCPConn
+	faults Faults
+
+	mutex     sync.Mutex
+	forwarded int64
+	closeOnce sync.Once
+}
+
+func (c *proxiedConn) run() {
+	done := make(chan struct{}, 2)
+	go func() {
+		c.forward(c.server, c.client, false)
+		done <- struct{}{}
+	}()
+	go func() {
+		c.forward(c.client, c.server, true)
+		done <- struct{}{}
+	}()
+
+	<-done
+	<-done
+	c.close()
+}
+
+func (c *proxiedConn) forward(dst, src *net.TCPConn, fromClient bool) {
+	buffer := make([]byte, 32*1024)
+	var fromServer int64
+
+	for {
+		n, err := src.Read(buffer)
+		if n > 0 {
+			chunk := buffer[:n]
+
+			if fromClient && c.faults.ResetProcessStreamsAfter > 0 && processRequest.Match(chunk) {
+				time.AfterFunc(c.faults.ResetProcessStreamsAfter, c.reset)
+			}
+
+			if !fromClient && c.faults.TruncateResponsesAfterBytes > 0 {
+				remaining := c.faults.TruncateResponsesAfterBytes - fromServer
+				if int64(len(chunk)) >= remaining {
+					chunk = chunk[:remaining]
+					err = io.EOF
+				}
+				fromServer += int64(len(chunk))
+			}
+
+			if c.faults.Latency > 0 {
+				time.Sleep(c.faults.Latency)
+			}
+
+			chunk, drop := c.count(chunk)
+			if _, writeErr := dst.Write(chunk); writeErr != nil {
+				err = writeErr
+			}
+			if drop {
+				c.close()
+				return
+			}
+		}
+
+		if err != nil {
+			dst.CloseWrite()
+			return
+		}
+	}
+}
+
+// count adds chunk to the bytes forwarded, cutting it short if that reaches
+// DropAfterBytes.
+func (c *proxiedConn) count(chunk []byte) ([]byte, bool) {
+	if c.faults.DropAfterBytes == 0 {
+		return chunk, false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	remaining := c.faults.DropAfterBytes - c.forwarded
+	if int64(len(chunk)) >= remaining {
+		c.forwarded = c.faults.DropAfterBytes
+		return chunk[:remaining], true
+	}
+	c.forwarded += int64(len(chunk))
+	return chunk, false
+}
+
+func (c *proxiedConn) close() {
+	c.closeOnce.Do(func() {
+		c.client.Close()
+		c.server.Close()
+	})
+}
+
+func (c *proxiedConn) reset() {
+	c.client.SetLinger(0)
+	c.server.SetLinger(0)
+	c.close()
+}
@@ -0,0 +1,13 @@
+package faultproxy_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestFaultproxy(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Faultproxy Suite")
+}
@@ -0,0 +1,130 @@
+package faultproxy_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden-acceptance/faultproxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Proxy", func() {
+	var server net.Listener
+	var proxy *faultproxy.Proxy
+
+	BeforeEach(func() {
+		var err error
+		server, err = net.Listen("tcp", "127.0.0.1:0")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		// echo every connection back until the client finishes writing
+		go func() {
+			for {
+				conn, err := server.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					io.Copy(conn, conn)
+					conn.Close()
+				}()
+			}
+		}()
+
+		proxy, err = faultproxy.New(server.Addr().String())
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		proxy.Close()
+		server.Close()
+	})
+
+	exchange := func(request string) (string, error) {
+		conn, err := net.Dial("tcp", proxy.Addr())
+		Ω(err).ShouldNot(HaveOccurred())
+		defer conn.Close()
+
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		if _, err := conn.Write([]byte(request)); err != nil {
+			return "", err
+		}
+		conn.(*net.TCPConn).CloseWrite()
+
+		response, err := ioutil.ReadAll(conn)
+		return string(response), err
+	}
+
+	It("forwards traffic untouched by default", func() {
+		Ω(exchange("hello")).Should(Equal("hello"))
+	})
+
+	It("adds latency", func() {
+		proxy.SetFaults(faultproxy.Faults{Latency: 100 * time.Millisecond})
+
+		start := time.Now()
+		Ω(exchange("hello")).Should(Equal("hello"))
+		Ω(time.Since(start)).Should(BeNumerically(">=", 200*time.Millisecond))
+	})
+
+	It("truncates responses", func() {
+		proxy.SetFaults(faultproxy.Faults{TruncateResponsesAfterBytes: 3})
+		Ω(exchange("hello")).Should(Equal("hel"))
+	})
+
+	It("drops connections after a number of bytes", func() {
+		proxy.SetFaults(faultproxy.Faults{DropAfterBytes: 8})
+
+		response, _ := exchange("hello")
+		Ω(response).Should(Equal("hel"))
+	})
+
+	It("resets process streams", func() {
+		proxy.SetFaults(faultproxy.Faults{ResetProcessStreamsAfter: 50 * time.Millisecond})
+
+		conn, err := net.Dial("tcp", proxy.Addr())
+		Ω(err).ShouldNot(HaveOccurred())
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("POST /containers/handle/processes HTTP/1.1\r\n\r\n"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err = io.Copy(ioutil.Discard, conn)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("reset"))
+	})
+
+	It("leaves other requests alone when resetting process streams", func() {
+		proxy.SetFaults(faultproxy.Faults{ResetProcessStreamsAfter: time.Millisecond})
+
+		request := "GET /containers/handle/info HTTP/1.1\r\n\r\n"
+		Ω(exchange(request)).Should(Equal(request))
+	})
+
+	It("drops open connections on demand", func() {
+		conn, err := net.Dial("tcp", proxy.Addr())
+		Ω(err).ShouldNot(HaveOccurred())
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("hello"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Eventually(func() bool {
+			buffer := make([]byte, 5)
+			conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			n, _ := conn.Read(buffer)
+			return bytes.Equal(buffer[:n], []byte("hello"))
+		}).Should(BeTrue())
+
+		proxy.DropAll()
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		Ω(err).Should(Equal(io.EOF))
+	})
+})
@@ -10,6 +10,8 @@ import (
 
 var _ = Describe("fusefs", func() {
 	It("can be mounted", func() {
+		requireCapabilities(privilegedContainers, fuse)
+
 		container := createContainer(gardenClient, garden.ContainerSpec{Privileged: true, RootFSPath: "/var/vcap/packages/rootfs/fusefs"})
 		mountpoint := "/tmp/fuse-test"
 
@@ -2,13 +2,22 @@ package garden_acceptance_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
-	"net"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-acceptance/faultproxy"
+	"github.com/cloudfoundry-incubator/garden-acceptance/gardentest"
+	"github.com/cloudfoundry-incubator/garden-acceptance/recorder"
 	"github.com/cloudfoundry-incubator/garden/client"
 	"github.com/cloudfoundry-incubator/garden/client/connection"
 
@@ -24,17 +33,53 @@ func TestGardenAcceptance(t *testing.T) {
 
 var gardenClient client.Client
 
+// gardenRecorder records the current spec's Garden traffic so that it can be
+// saved if the spec fails; see saveRecording.
+var gardenRecorder *recorder.Recorder
+
+// gardenProxy sits between gardenClient and the server so that specs can
+// inject network faults. It forwards untouched unless a spec sets faults.
+var gardenProxy *faultproxy.Proxy
+
 var hostIP = "10.244.16.6"
 
+var gardenAddress = hostIP + ":7777"
+
+// probes are small static binaries from cmd/ that are built on the host and
+// bind-mounted into containers at probesPath. They are built for linux/amd64,
+// whose syscall numbers syscallprobe uses.
+var probes = []string{"netinfo", "escapeprobe", "syscallprobe", "dnsstandin"}
+
+const probesPath = "/var/garden-acceptance/bin"
+
+var probesDir string
+
 var _ = BeforeSuite(func() {
-	gardenClient = client.New(connection.New("tcp", hostIP+":7777"))
+	var err error
+	gardenProxy, err = faultproxy.New(gardenAddress)
+	Ω(err).ShouldNot(HaveOccurred())
+
+	gardenRecorder = recorder.New(connection.New("tcp", gardenProxy.Addr()))
+	gardenClient = client.New(gardenRecorder)
+	probesDir = buildProbes(probes)
+	probeCapabilities()
+})
+
+var _ = AfterSuite(func() {
+	gardenProxy.Close()
+	os.RemoveAll(probesDir)
 })
 
 var _ = BeforeEach(func() {
+	gardenRecorder.Reset()
 	destroyAllContainers(gardenClient)
 })
 
 var _ = AfterEach(func() {
+	gardenProxy.SetFaults(faultproxy.Faults{})
+	if CurrentGinkgoTestDescription().Failed {
+		saveRecording()
+	}
 	destroyAllContainers(gardenClient)
 })
 
@@ -48,27 +93,9 @@ func recordedProcessIO(buffer *gbytes.Buffer) garden.ProcessIO {
 	}
 }
 
-func pingRule(ip string) garden.NetOutRule {
-	return garden.NetOutRule{
-		Protocol: garden.ProtocolICMP,
-		Networks: []garden.IPRange{garden.IPRangeFromIP(net.ParseIP(ip))},
-	}
-}
-
-func runCommand(cmd string) (string, string, error) {
-	var stdout, stderr bytes.Buffer
-	command := exec.Command("sh", "-c", cmd)
-	command.Stdout = &stdout
-	command.Stderr = &stderr
-	err := command.Run()
-	return stdout.String(), stderr.String(), err
-}
-
 // TODO: Make user an explicit argument, always
 func runInContainer(container garden.Container, cmd string) (string, string, error) {
-	info, err := container.Info()
-	Ω(err).ShouldNot(HaveOccurred())
-	return runCommand(fmt.Sprintf("cd %v && sudo ./bin/wsh %v", info.ContainerPath, cmd))
+	return gardentest.RunInContainer(container, cmd)
 }
 
 func runInContainerSuccessfully(container garden.Container, cmd string) string {
@@ -84,11 +111,93 @@ func createContainer(client garden.Client, spec garden.ContainerSpec) garden.Con
 }
 
 func destroyAllContainers(client client.Client) {
-	containers, err := client.Containers(nil)
-	Ω(err).ShouldNot(HaveOccurred(), "Error while listing containers")
+	Ω(gardentest.DestroyAll(client)).Should(Succeed())
+}
+
+// tarFile returns a tar archive, for StreamIn, holding one file.
+func tarFile(name string, content []byte) *bytes.Buffer {
+	buffer, err := gardentest.TarFile(name, content)
+	Ω(err).ShouldNot(HaveOccurred())
+	return buffer
+}
+
+// restartGarden restarts the garden job on the host and waits for it to
+// answer again.
+func restartGarden() {
+	Ω(gardentest.RestartGarden(gardenClient, 60*time.Second)).Should(Succeed())
+}
+
+func buildProbes(names []string) string {
+	dir, err := ioutil.TempDir("", "garden-acceptance-probes")
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(os.Chmod(dir, 0755)).Should(Succeed())
+
+	for _, name := range names {
+		command := exec.Command("go", "build", "-o", filepath.Join(dir, name), "github.com/cloudfoundry-incubator/garden-acceptance/cmd/"+name)
+		command.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS=linux", "GOARCH=amd64")
+		output, err := command.CombinedOutput()
+		Ω(err).ShouldNot(HaveOccurred(), fmt.Sprintf("Error while building probe %s: %s", name, output))
+	}
+
+	return dir
+}
+
+func withProbes(spec garden.ContainerSpec) garden.ContainerSpec {
+	spec.BindMounts = append(spec.BindMounts, garden.BindMount{
+		SrcPath: probesDir,
+		DstPath: probesPath,
+		Mode:    garden.BindMountModeRO,
+	})
+	return spec
+}
+
+// runProbe runs a probe as root in a container created withProbes and
+// decodes its JSON output into result.
+func runProbe(container garden.Container, result interface{}, name string, args ...string) {
+	runProbeAs("root", container, result, name, args...)
+}
+
+func runProbeAs(user string, container garden.Container, result interface{}, name string, args ...string) {
+	stdout := gbytes.NewBuffer()
+	process, err := container.Run(garden.ProcessSpec{
+		User: user,
+		Path: filepath.Join(probesPath, name),
+		Args: args,
+	}, garden.ProcessIO{Stdout: io.MultiWriter(stdout, GinkgoWriter), Stderr: GinkgoWriter})
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(process.Wait()).Should(Equal(0), fmt.Sprintf("Probe %s failed", name))
+	Ω(json.Unmarshal(stdout.Contents(), result)).Should(Succeed())
+}
 
-	for _, container := range containers {
-		err = client.Destroy(container.Handle())
-		Ω(err).ShouldNot(HaveOccurred(), fmt.Sprintf("Error while destroying container %+v", container.Handle()))
+func envInt(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
 	}
+	parsed, err := strconv.Atoi(strings.TrimSpace(value))
+	Ω(err).ShouldNot(HaveOccurred(), fmt.Sprintf("Invalid %s", name))
+	return parsed
+}
+
+// saveRecording writes the current spec's Garden traffic to
+// GARDEN_ACCEPTANCE_RECORDINGS (default a garden-acceptance-recordings
+// directory under the system temp dir) and names the file in the spec's
+// output. Replay it with cmd/garden-replay.
+func saveRecording() {
+	dir := gardentest.EnvOrDefault("GARDEN_ACCEPTANCE_RECORDINGS", filepath.Join(os.TempDir(), "garden-acceptance-recordings"))
+	Ω(os.MkdirAll(dir, 0755)).Should(Succeed())
+
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, CurrentGinkgoTestDescription().FullTestText)
+	if len(name) > 100 {
+		name = name[:100]
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-%d.jsonl", name, time.Now().Unix()))
+	Ω(gardenRecorder.Save(path)).Should(Succeed())
+	fmt.Fprintf(GinkgoWriter, "Garden traffic for this spec recorded to %s (%d events)\n", path, len(gardenRecorder.Events()))
 }
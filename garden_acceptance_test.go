@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-acceptance/gardentest"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -30,6 +31,7 @@ var _ = Describe("Garden Acceptance Tests", func() {
 
 		Context("that's privileged", func() {
 			BeforeEach(func() {
+				requireCapabilities(privilegedContainers)
 				container = createContainer(gardenClient, garden.ContainerSpec{Privileged: true})
 			})
 
@@ -103,12 +105,8 @@ var _ = Describe("Garden Acceptance Tests", func() {
 			})
 
 			It("can set a property (#87599106)", func() {
-				err := container.SetProperty("foo", "bar")
-				Ω(err).ShouldNot(HaveOccurred())
-
-				value, err := container.Property("foo")
-				Ω(err).ShouldNot(HaveOccurred())
-				Ω(value).Should(Equal("bar"))
+				Ω(container.SetProperty("foo", "bar")).Should(Succeed())
+				Ω(container).Should(HaveProperty("foo", "bar"))
 			})
 		})
 
@@ -126,7 +124,7 @@ var _ = Describe("Garden Acceptance Tests", func() {
 				_, err = fmt.Fscanf(pidFile, "%d", &pid)
 				Ω(err).ShouldNot(HaveOccurred())
 
-				_, _, err = runCommand("sudo kill -9 " + strconv.Itoa(pid))
+				_, _, err = gardentest.RunCommand("sudo kill -9 " + strconv.Itoa(pid))
 				Ω(err).ShouldNot(HaveOccurred())
 
 				err = gardenClient.Destroy(container.Handle())
@@ -189,11 +187,9 @@ var _ = Describe("Garden Acceptance Tests", func() {
 			})
 
 			It("can run processes as root", func() {
-				buffer := gbytes.NewBuffer()
-				process, err := container.Run(garden.ProcessSpec{User: "root", Path: "whoami"}, recordedProcessIO(buffer))
-				Ω(err).ShouldNot(HaveOccurred())
-				Ω(process.Wait()).Should(Equal(0))
-				Ω(buffer.Contents()).Should(ContainSubstring("root"))
+				result := runIn(container).As("root").Command("whoami").Wait()
+				Ω(result).Should(ExitWith(0))
+				Ω(result).Should(HaveStdout(ContainSubstring("root")))
 			})
 
 			It("can run processes with rlimits", func() {
@@ -216,13 +212,15 @@ var _ = Describe("Garden Acceptance Tests", func() {
 	Describe("iodaemon", func() {
 		It("supports a timeout when the process fails to link (#77842604)", func() {
 			iodaemon := "/home/vagrant/go/src/github.com/cloudfoundry-incubator/garden-linux/old/linux_backend/skeleton/bin/iodaemon"
-			stdout, _, err := runCommand("timeout 3s " + iodaemon + " -timeout 1s spawn /tmp/socketPath bash -c cat <&0; echo $?")
+			stdout, _, err := gardentest.RunCommand("timeout 3s " + iodaemon + " -timeout 1s spawn /tmp/socketPath bash -c cat <&0; echo $?")
 			Ω(err).ShouldNot(HaveOccurred())
 			Ω(stdout).NotTo(ContainSubstring("124"), "124 means `timeout` timed out.")
 		})
 	})
 
 	It("cleans up after running processes (#89969450)", func() {
+		requireCapabilities(containerPathOnHost)
+
 		container := createContainer(gardenClient, garden.ContainerSpec{})
 		for i := 0; i < 10; i++ {
 			_, err := container.Run(lsProcessSpec, silentProcessIO)
@@ -234,7 +232,7 @@ var _ = Describe("Garden Acceptance Tests", func() {
 		processesPath := info.ContainerPath + "/processes"
 
 		Eventually(func() string {
-			_, stderr, _ := runCommand("cd " + processesPath + " && ls *.sock")
+			_, stderr, _ := gardentest.RunCommand("cd " + processesPath + " && ls *.sock")
 			return stderr
 		}).Should(ContainSubstring("No such file or directory"))
 	})
@@ -257,7 +255,9 @@ var _ = Describe("Garden Acceptance Tests", func() {
 			Ω(err).Should(MatchError(garden.ContainerNotFoundError{Handle: "asdf"}))
 		})
 
-		PIt("does not leak network namespaces (Bug #91423716)", func() {
+		It("does not leak network namespaces (Bug #91423716)", func() {
+			requireCapabilities(containerPathOnHost, hostNetworkNamespaces)
+
 			container := createContainer(gardenClient, garden.ContainerSpec{})
 			info, err := container.Info()
 			Ω(err).ShouldNot(HaveOccurred())
@@ -272,7 +272,7 @@ var _ = Describe("Garden Acceptance Tests", func() {
 			err = gardenClient.Destroy(container.Handle())
 			Ω(err).ShouldNot(HaveOccurred())
 
-			stdout, _, err := runCommand("ip netns list")
+			stdout, _, err := gardentest.RunCommand("ip netns list")
 			Ω(err).ShouldNot(HaveOccurred())
 			Ω(stdout).ShouldNot(ContainSubstring(strconv.Itoa(pid)))
 		})
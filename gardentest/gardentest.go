@@ -0,0 +1,180 @@
+// Package gardentest holds the helpers shared by the acceptance suite and the
+// gardenprobe command: running commands on the Garden host, building NetOut
+// rules and tarballs, running processes, restarting Garden and reading
+// settings from the environment.
+package gardentest
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+)
+
+// RunCommand runs cmd with sh on the local machine, which for the suite is
+// the Garden host.
+func RunCommand(cmd string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	command := exec.Command("sh", "-c", cmd)
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	err := command.Run()
+	return stdout.String(), stderr.String(), err
+}
+
+// RunInContainer runs cmd as root in container with wsh, bypassing Garden.
+// It only works on the Garden host.
+func RunInContainer(container garden.Container, cmd string) (string, string, error) {
+	info, err := container.Info()
+	if err != nil {
+		return "", "", err
+	}
+	return RunCommand(fmt.Sprintf("cd %v && sudo ./bin/wsh %v", info.ContainerPath, cmd))
+}
+
+type Result struct {
+	ExitCode int
+	Stdout   []byte
+	Stderr   []byte
+}
+
+// Run runs spec in container to completion, copying its output to echo if
+// it is not nil as well as collecting it.
+func Run(container garden.Container, spec garden.ProcessSpec, stdin io.Reader, echo io.Writer) (Result, error) {
+	var stdout, stderr bytes.Buffer
+	processIO := garden.ProcessIO{Stdin: stdin, Stdout: &stdout, Stderr: &stderr}
+	if echo != nil {
+		processIO.Stdout = io.MultiWriter(&stdout, echo)
+		processIO.Stderr = io.MultiWriter(&stderr, echo)
+	}
+
+	process, err := container.Run(spec, processIO)
+	if err != nil {
+		return Result{}, err
+	}
+
+	exitCode, err := process.Wait()
+	return Result{ExitCode: exitCode, Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}, err
+}
+
+func PingRule(ip string) garden.NetOutRule {
+	return garden.NetOutRule{
+		Protocol: garden.ProtocolICMP,
+		Networks: []garden.IPRange{garden.IPRangeFromIP(net.ParseIP(ip))},
+	}
+}
+
+// TCPRule allows, and logs, TCP to ip on port.
+func TCPRule(ip string, port uint16) garden.NetOutRule {
+	return garden.NetOutRule{
+		Protocol: garden.ProtocolTCP,
+		Networks: []garden.IPRange{garden.IPRangeFromIP(net.ParseIP(ip))},
+		Ports:    []garden.PortRange{garden.PortRangeFromPort(port)},
+		Log:      true,
+	}
+}
+
+func UDPRule(ip string, port uint16) garden.NetOutRule {
+	return garden.NetOutRule{
+		Protocol: garden.ProtocolUDP,
+		Networks: []garden.IPRange{garden.IPRangeFromIP(net.ParseIP(ip))},
+		Ports:    []garden.PortRange{garden.PortRangeFromPort(port)},
+	}
+}
+
+// ParseIPRange accepts "1.2.3.4", "1.2.3.4-1.2.3.9" or "1.2.3.0/24".
+func ParseIPRange(network string) (garden.IPRange, error) {
+	if _, ipNet, err := net.ParseCIDR(network); err == nil && ipNet.IP.To4() != nil {
+		ones, bits := ipNet.Mask.Size()
+		start := binary.BigEndian.Uint32(ipNet.IP.To4())
+		end := make(net.IP, 4)
+		binary.BigEndian.PutUint32(end, start|(1<<uint(bits-ones)-1))
+		return garden.IPRange{Start: ipNet.IP.To4(), End: end}, nil
+	}
+
+	if parts := strings.SplitN(network, "-", 2); len(parts) == 2 {
+		start, end := net.ParseIP(parts[0]), net.ParseIP(parts[1])
+		if start == nil || end == nil {
+			return garden.IPRange{}, fmt.Errorf("invalid network %q", network)
+		}
+		return garden.IPRange{Start: start, End: end}, nil
+	}
+
+	ip := net.ParseIP(network)
+	if ip == nil {
+		return garden.IPRange{}, fmt.Errorf("invalid network %q", network)
+	}
+	return garden.IPRangeFromIP(ip), nil
+}
+
+// TarFile returns a tar archive, for StreamIn, holding one file.
+func TarFile(name string, content []byte) (*bytes.Buffer, error) {
+	buffer := new(bytes.Buffer)
+	writer := tar.NewWriter(buffer)
+	if err := writer.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(content); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer, nil
+}
+
+// DestroyAll destroys every container, stopping at the first failure.
+func DestroyAll(client garden.Client) error {
+	containers, err := client.Containers(nil)
+	if err != nil {
+		return fmt.Errorf("listing containers: %s", err)
+	}
+
+	for _, container := range containers {
+		if err := client.Destroy(container.Handle()); err != nil {
+			return fmt.Errorf("destroying %s: %s", container.Handle(), err)
+		}
+	}
+	return nil
+}
+
+// RestartGarden restarts the garden job with monit, which only works on the
+// Garden host, and waits up to timeout for client to answer again.
+func RestartGarden(client garden.Client, timeout time.Duration) error {
+	if _, stderr, err := RunCommand("sudo /var/vcap/bosh/bin/monit restart garden"); err != nil {
+		return fmt.Errorf("monit restart garden: %s: %s", err, stderr)
+	}
+
+	// monit returns before garden has stopped, so an early ping may still
+	// reach the old server
+	time.Sleep(5 * time.Second)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := client.Ping()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("garden did not come back within %s: %s", timeout, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// EnvOrDefault returns the environment variable name, or defaultValue if it
+// is unset or empty.
+func EnvOrDefault(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
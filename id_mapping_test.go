@@ -0,0 +1,173 @@
+package garden_acceptance_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/cloudfoundry-incubator/garden"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// idMapping is one line of /proc/<pid>/uid_map or gid_map: Size ids from
+// Inside in the container are Outside on the host.
+type idMapping struct {
+	Inside, Outside, Size uint64
+}
+
+type idMap []idMapping
+
+// identityIDMap is what a process outside any user namespace sees.
+var identityIDMap = idMap{{Inside: 0, Outside: 0, Size: 4294967295}}
+
+func parseIDMap(contents string) (idMap, error) {
+	var mappings idMap
+	for _, line := range strings.Split(strings.TrimSpace(contents), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed id map line %q", line)
+		}
+
+		var numbers [3]uint64
+		for i, field := range fields {
+			n, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("malformed id map line %q: %s", line, err)
+			}
+			numbers[i] = n
+		}
+		mappings = append(mappings, idMapping{Inside: numbers[0], Outside: numbers[1], Size: numbers[2]})
+	}
+	return mappings, nil
+}
+
+// toHost returns the host id for a container id, and false if it is not
+// mapped.
+func (m idMap) toHost(id uint64) (uint64, bool) {
+	for _, mapping := range m {
+		if id >= mapping.Inside && id < mapping.Inside+mapping.Size {
+			return mapping.Outside + id - mapping.Inside, true
+		}
+	}
+	return 0, false
+}
+
+var _ = Describe("uid and gid mapping", func() {
+	const mountPath = "/home/alice/idmap"
+
+	var hostDir string
+
+	BeforeEach(func() {
+		var err error
+		hostDir, err = ioutil.TempDir("", "garden-acceptance-idmap")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(os.Chmod(hostDir, 0777)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(hostDir)
+	})
+
+	readIDMap := func(container garden.Container, file string) idMap {
+		result := runIn(container).Command("cat", "/proc/self/"+file).Wait()
+		Ω(result).Should(ExitWith(0))
+		mappings, err := parseIDMap(string(result.Stdout.Contents()))
+		Ω(err).ShouldNot(HaveOccurred())
+		return mappings
+	}
+
+	containerID := func(container garden.Container, user, flag string) uint64 {
+		result := runIn(container).As(user).Command("id", flag).Wait()
+		Ω(result).Should(ExitWith(0))
+		id, err := strconv.ParseUint(strings.TrimSpace(string(result.Stdout.Contents())), 10, 32)
+		Ω(err).ShouldNot(HaveOccurred())
+		return id
+	}
+
+	rootFSes := []struct {
+		name         string
+		path         string
+		capabilities []capability
+	}{
+		{"a directory rootfs", "/var/vcap/packages/rootfs/alice", nil},
+		{"a docker rootfs", "docker:///cloudfoundry/garden-pm#alice", []capability{dockerRootFS}},
+	}
+
+	for _, rootFS := range rootFSes {
+		for _, privileged := range []bool{false, true} {
+			rootFS, privileged := rootFS, privileged
+
+			kind := "unprivileged"
+			if privileged {
+				kind = "privileged"
+			}
+
+			Context(fmt.Sprintf("in %s containers with %s", kind, rootFS.name), func() {
+				var container garden.Container
+				var uidMap, gidMap idMap
+
+				BeforeEach(func() {
+					requireCapabilities(rootFS.capabilities...)
+					if privileged {
+						requireCapabilities(privilegedContainers)
+					}
+
+					container = createContainer(gardenClient, garden.ContainerSpec{
+						RootFSPath: rootFS.path,
+						Privileged: privileged,
+						BindMounts: []garden.BindMount{{
+							SrcPath: hostDir,
+							DstPath: mountPath,
+							Mode:    garden.BindMountModeRW,
+						}},
+					})
+					uidMap = readIDMap(container, "uid_map")
+					gidMap = readIDMap(container, "gid_map")
+				})
+
+				if privileged {
+					It("does not remap uids or gids", func() {
+						Ω(uidMap).Should(Equal(identityIDMap))
+						Ω(gidMap).Should(Equal(identityIDMap))
+					})
+				} else {
+					It("maps root to an unprivileged host user and group", func() {
+						hostUID, ok := uidMap.toHost(0)
+						Ω(ok).Should(BeTrue(), "root is not in uid_map %v", uidMap)
+						Ω(hostUID).ShouldNot(BeZero())
+
+						hostGID, ok := gidMap.toHost(0)
+						Ω(ok).Should(BeTrue(), "root is not in gid_map %v", gidMap)
+						Ω(hostGID).ShouldNot(BeZero())
+					})
+				}
+
+				It("gives files created in a read/write bind mount the mapped owners on the host", func() {
+					for _, user := range []string{"root", "alice", "bob"} {
+						uid := containerID(container, user, "-u")
+						gid := containerID(container, user, "-g")
+
+						Ω(runIn(container).As(user).Command("touch", mountPath+"/"+user).Wait()).Should(ExitWith(0))
+
+						expectedUID, ok := uidMap.toHost(uid)
+						Ω(ok).Should(BeTrue(), "%s's uid %d is not in uid_map %v", user, uid, uidMap)
+						expectedGID, ok := gidMap.toHost(gid)
+						Ω(ok).Should(BeTrue(), "%s's gid %d is not in gid_map %v", user, gid, gidMap)
+
+						info, err := os.Stat(filepath.Join(hostDir, user))
+						Ω(err).ShouldNot(HaveOccurred())
+						stat := info.Sys().(*syscall.Stat_t)
+						Ω(uint64(stat.Uid)).Should(Equal(expectedUID), "owner of %s's file on the host", user)
+						Ω(uint64(stat.Gid)).Should(Equal(expectedGID), "group of %s's file on the host", user)
+					}
+				})
+			})
+		}
+	}
+})
@@ -11,9 +11,7 @@ var _ = Describe("info and metrics", func() {
 	Describe("Container.Info()", func() {
 		It("returns a container IP", func() {
 			container := createContainer(gardenClient, garden.ContainerSpec{Network: "10.1.1.1/16"})
-			info, err := container.Info()
-			Ω(err).ShouldNot(HaveOccurred())
-			Ω(info.ContainerIP).Should(Equal("10.1.1.1"))
+			Ω(container).Should(HaveContainerIP("10.1.1.1"))
 		})
 	})
 
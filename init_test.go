@@ -0,0 +1,120 @@
+package garden_acceptance_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-acceptance/gardentest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// defunctPIDs lists the zombie processes in container. It reads
+// /proc/<pid>/status because busybox ps does not show process states.
+func defunctPIDs(container garden.Container) []string {
+	result := runIn(container).Shell(`grep -l '^State:.*Z' /proc/[0-9]*/status 2>/dev/null`).Wait()
+
+	var pids []string
+	for _, path := range strings.Fields(string(result.Stdout.Contents())) {
+		pids = append(pids, filepath.Base(filepath.Dir(path)))
+	}
+	return pids
+}
+
+// hostProcesses counts processes on the Garden host whose command line is
+// exactly args.
+func hostProcesses(args string) int {
+	stdout, _, _ := gardentest.RunCommand("ps -eo args")
+
+	count := 0
+	for _, line := range strings.Split(stdout, "\n") {
+		if strings.TrimSpace(line) == args {
+			count++
+		}
+	}
+	return count
+}
+
+var _ = Describe("container init", func() {
+	var container garden.Container
+
+	BeforeEach(func() {
+		container = createContainer(gardenClient, garden.ContainerSpec{})
+	})
+
+	defunct := func() []string {
+		return defunctPIDs(container)
+	}
+
+	It("adopts double-forked daemons", func() {
+		script := `sh -c 'sleep 1000 </dev/null >/dev/null 2>&1 & echo $! > /tmp/daemon.pid'`
+		Ω(runIn(container).Shell(script).Wait()).Should(ExitWith(0))
+
+		result := runIn(container).Shell(`awk '{print $4}' /proc/$(cat /tmp/daemon.pid)/stat`).Wait()
+		Ω(result).Should(HaveStdout("1\n"), "Daemon's parent is not the container's init")
+	})
+
+	It("reaps orphans when they exit", func() {
+		script := `for i in $(seq 20); do sh -c 'sleep 0.5 </dev/null >/dev/null 2>&1 &'; done`
+		Ω(runIn(container).Shell(script).Wait()).Should(ExitWith(0))
+
+		Eventually(defunct, "5s").Should(BeEmpty())
+		Consistently(defunct, "3s").Should(BeEmpty())
+	})
+
+	It("reaps zombies whose parent exits without waiting for them", func() {
+		process, _ := runIn(container).Shell("sleep 0.1 & exec sleep 2").Start()
+		Eventually(defunct, "2s").Should(HaveLen(1))
+
+		Ω(process.Wait()).Should(Equal(0))
+		Eventually(defunct, "5s").Should(BeEmpty())
+	})
+
+	It("leaves no defunct processes over time", func() {
+		process, _ := runIn(container).Shell(`
+			while true; do
+				sh -c 'sleep 0.1 </dev/null >/dev/null 2>&1 &'
+				sleep 0.2 &
+				sleep 0.1
+			done
+		`).Start()
+
+		for i := 0; i < 10; i++ {
+			time.Sleep(time.Second)
+			Ω(len(defunct())).Should(BeNumerically("<=", 2), "Zombies are piling up")
+		}
+
+		Ω(process.Signal(garden.SignalKill)).Should(Succeed())
+		process.Wait()
+		Eventually(defunct, "5s").Should(BeEmpty())
+	})
+
+	Describe("Destroy", func() {
+		It("kills the whole process tree, including processes that left the session", func() {
+			requireCapabilities(containerPathOnHost)
+
+			// unique command lines, so that the host-side count only sees
+			// this container's processes
+			seconds := 1000000 + int(time.Now().UnixNano()%1000000)
+			fromRun := fmt.Sprintf("sleep %d", seconds)
+			fromRunSetsid := fmt.Sprintf("sleep %d", seconds+1)
+			fromWsh := fmt.Sprintf("sleep %d", seconds+2)
+
+			runIn(container).Shell(fromRun + " & " + fromRun + " & wait").Start()
+			Ω(runIn(container).Shell("setsid " + fromRunSetsid + " </dev/null >/dev/null 2>&1 &").Wait()).Should(ExitWith(0))
+			runInContainerSuccessfully(container, fmt.Sprintf(`sh -c 'setsid %s </dev/null >/dev/null 2>&1 &'`, fromWsh))
+
+			running := func() []int {
+				return []int{hostProcesses(fromRun), hostProcesses(fromRunSetsid), hostProcesses(fromWsh)}
+			}
+			Eventually(running, "5s").Should(Equal([]int{2, 1, 1}))
+
+			Ω(gardenClient.Destroy(container.Handle())).Should(Succeed())
+			Eventually(running, "10s").Should(Equal([]int{0, 0, 0}))
+		})
+	})
+})
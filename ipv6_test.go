@@ -0,0 +1,148 @@
+package garden_acceptance_test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-acceptance/gardentest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("IPv6 networking", func() {
+	BeforeEach(func() {
+		requireCapabilities(ipv6)
+	})
+
+	It("gives containers a global IPv6 address", func() {
+		container := createContainer(gardenClient, withProbes(garden.ContainerSpec{}))
+		Ω(containerIPv6Addresses(container)).ShouldNot(BeEmpty())
+	})
+
+	It("gives containers distinct IPv6 addresses", func() {
+		one := createContainer(gardenClient, withProbes(garden.ContainerSpec{}))
+		two := createContainer(gardenClient, withProbes(garden.ContainerSpec{}))
+
+		for _, ip := range containerIPv6Addresses(one) {
+			Ω(containerIPv6Addresses(two)).ShouldNot(ContainElement(ip))
+		}
+	})
+
+	It("supports NetIn over IPv6", func() {
+		container := createContainer(gardenClient, withProbes(garden.ContainerSpec{}))
+		hostPort, containerPort, err := container.NetIn(0, 0)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		_, err = container.Run(garden.ProcessSpec{
+			User: "root",
+			Path: "sh",
+			Args: []string{"-c", fmt.Sprintf("echo hello | nc -l -p %d", containerPort)},
+		}, silentProcessIO)
+		Ω(err).ShouldNot(HaveOccurred())
+		time.Sleep(time.Millisecond * 100)
+
+		conn, err := net.Dial("tcp6", net.JoinHostPort(hostIPv6.String(), fmt.Sprintf("%d", hostPort)))
+		Ω(err).ShouldNot(HaveOccurred())
+		defer conn.Close()
+
+		message, err := bufio.NewReader(conn).ReadString('\n')
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(message).Should(Equal("hello\n"))
+	})
+
+	Describe("NetOut rules", func() {
+		const googleDNSv6 = "2001:4860:4860::8888"
+
+		ping6 := func(container garden.Container) (int, *gbytes.Buffer) {
+			buffer := gbytes.NewBuffer()
+			process, err := container.Run(garden.ProcessSpec{
+				User: "root",
+				Path: "ping6",
+				Args: []string{"-c", "1", "-w", "3", googleDNSv6},
+			}, recordedProcessIO(buffer))
+			Ω(err).ShouldNot(HaveOccurred())
+			status, err := process.Wait()
+			Ω(err).ShouldNot(HaveOccurred())
+			return status, buffer
+		}
+
+		It("denies outbound IPv6 traffic by default", func() {
+			container := createContainer(gardenClient, withProbes(garden.ContainerSpec{}))
+			status, _ := ping6(container)
+			Ω(status).ShouldNot(Equal(0))
+		})
+
+		It("can open outbound ICMPv6 connections", func() {
+			container := createContainer(gardenClient, withProbes(garden.ContainerSpec{}))
+			Ω(container.NetOut(gardentest.PingRule(googleDNSv6))).Should(Succeed())
+
+			status, buffer := ping6(container)
+			Ω(status).Should(Equal(0))
+			Ω(buffer).Should(gbytes.Say("64 bytes from"))
+		})
+
+		It("can open outbound TCP connections to IPv6 ranges", func() {
+			container := createContainer(gardenClient, withProbes(garden.ContainerSpec{}))
+			Ω(container.NetOut(garden.NetOutRule{
+				Protocol: garden.ProtocolTCP,
+				Networks: []garden.IPRange{{
+					Start: net.ParseIP("2001:4860:4860::"),
+					End:   net.ParseIP("2001:4860:4860::ffff"),
+				}},
+				Ports: []garden.PortRange{garden.PortRangeFromPort(53)},
+			})).Should(Succeed())
+
+			process, err := container.Run(garden.ProcessSpec{
+				User: "root",
+				Path: "nc",
+				Args: []string{"-z", "-w", "3", googleDNSv6, "53"},
+			}, silentProcessIO)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(process.Wait()).Should(Equal(0))
+		})
+	})
+})
+
+func hostGlobalIPv6() net.IP {
+	interfaces, err := net.Interfaces()
+	Ω(err).ShouldNot(HaveOccurred())
+
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		var onHostInterface bool
+		var global net.IP
+		for _, addr := range addrs {
+			ip, _, err := net.ParseCIDR(addr.String())
+			if err != nil {
+				continue
+			}
+			if ip.String() == hostIP {
+				onHostInterface = true
+			}
+			if ip.To4() == nil && ip.IsGlobalUnicast() {
+				global = ip
+			}
+		}
+		if onHostInterface {
+			return global
+		}
+	}
+	return nil
+}
+
+func containerIPv6Addresses(container garden.Container) []string {
+	var addresses []string
+	for _, iface := range containerNetInfo(container).Interfaces {
+		for _, address := range iface.GlobalIPv6() {
+			addresses = append(addresses, address.IP)
+		}
+	}
+	return addresses
+}
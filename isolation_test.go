@@ -0,0 +1,171 @@
+package garden_acceptance_test
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("container-to-container isolation", func() {
+	members := []struct {
+		name string
+		spec garden.ContainerSpec
+	}{
+		{"shared-privileged", garden.ContainerSpec{Network: "10.2.0.0/24", Privileged: true}},
+		{"shared-unprivileged", garden.ContainerSpec{Network: "10.2.0.0/24"}},
+		{"distinct-privileged", garden.ContainerSpec{Network: "10.3.0.0/24", Privileged: true}},
+		{"distinct-unprivileged", garden.ContainerSpec{Network: "10.4.0.0/24"}},
+	}
+
+	// Rows are sources and columns are destinations, both in the order of
+	// members above. "x" means the source can reach the destination.
+	expectedMatrices := map[string][]string{
+		"ICMP": {"-xxx", "x-xx", "xx-x", "xxx-"},
+		"TCP":  {"-xxx", "x-xx", "xx-x", "xxx-"},
+		"UDP":  {"-xxx", "x-xx", "xx-x", "xxx-"},
+	}
+
+	var containers []garden.Container
+	var ips []string
+
+	BeforeEach(func() {
+		requireCapabilities(privilegedContainers)
+
+		containers, ips = nil, nil
+		for _, member := range members {
+			container := createContainer(gardenClient, member.spec)
+			info, err := container.Info()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			containers = append(containers, container)
+			ips = append(ips, info.ContainerIP)
+		}
+	})
+
+	verifyMatrix := func(protocol string, reachable func(src, dst garden.Container, dstIP string) bool) {
+		var actual []string
+		for i, src := range containers {
+			row := ""
+			for j, dst := range containers {
+				switch {
+				case i == j:
+					row += "-"
+				case reachable(src, dst, ips[j]):
+					row += "x"
+				default:
+					row += "."
+				}
+			}
+			actual = append(actual, row)
+		}
+
+		var names []string
+		for _, member := range members {
+			names = append(names, member.name)
+		}
+
+		expected := expectedMatrices[protocol]
+		Ω(actual).Should(Equal(expected), fmt.Sprintf(
+			"%s reachability changed\n\nexpected:\n%s\n\nactual:\n%s",
+			protocol, renderIsolationMatrix(names, expected), renderIsolationMatrix(names, actual),
+		))
+	}
+
+	It("matches the expected ICMP reachability", func() {
+		verifyMatrix("ICMP", func(src, dst garden.Container, dstIP string) bool {
+			process, err := src.Run(garden.ProcessSpec{
+				User: "root",
+				Path: "ping",
+				Args: []string{"-c", "1", "-w", "2", dstIP},
+			}, silentProcessIO)
+			Ω(err).ShouldNot(HaveOccurred())
+			status, err := process.Wait()
+			Ω(err).ShouldNot(HaveOccurred())
+			return status == 0
+		})
+	})
+
+	It("matches the expected TCP reachability", func() {
+		verifyMatrix("TCP", func(src, dst garden.Container, dstIP string) bool {
+			token := fmt.Sprintf("tcp-%s-%s", src.Handle(), dst.Handle())
+			// nc is the process itself, not a child of a shell, so that
+			// killAndWait stops whatever holds the port
+			listener, err := dst.Run(garden.ProcessSpec{
+				User: "root",
+				Path: "nc",
+				Args: []string{"-l", "-p", "7000"},
+			}, garden.ProcessIO{
+				Stdin:  strings.NewReader(token + "\n"),
+				Stdout: GinkgoWriter,
+				Stderr: GinkgoWriter,
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer killAndWait(listener)
+			time.Sleep(time.Millisecond * 100)
+
+			buffer := gbytes.NewBuffer()
+			process, err := src.Run(garden.ProcessSpec{
+				User: "root",
+				Path: "nc",
+				Args: []string{"-w", "2", dstIP, "7000"},
+			}, recordedProcessIO(buffer))
+			Ω(err).ShouldNot(HaveOccurred())
+			process.Wait()
+
+			return strings.Contains(string(buffer.Contents()), token)
+		})
+	})
+
+	It("matches the expected UDP reachability", func() {
+		verifyMatrix("UDP", func(src, dst garden.Container, dstIP string) bool {
+			token := fmt.Sprintf("udp-%s-%s", src.Handle(), dst.Handle())
+			received := gbytes.NewBuffer()
+			listener, err := dst.Run(garden.ProcessSpec{
+				User: "root",
+				Path: "nc",
+				Args: []string{"-u", "-l", "-p", "7001"},
+			}, recordedProcessIO(received))
+			Ω(err).ShouldNot(HaveOccurred())
+			defer killAndWait(listener)
+			time.Sleep(time.Millisecond * 100)
+
+			process, err := src.Run(garden.ProcessSpec{
+				User: "root",
+				Path: "sh",
+				Args: []string{"-c", fmt.Sprintf("echo %s | nc -u -w 1 %s 7001", token, dstIP)},
+			}, silentProcessIO)
+			Ω(err).ShouldNot(HaveOccurred())
+			process.Wait()
+
+			deadline := time.Now().Add(2 * time.Second)
+			for time.Now().Before(deadline) {
+				if strings.Contains(string(received.Contents()), token) {
+					return true
+				}
+				time.Sleep(time.Millisecond * 100)
+			}
+			return false
+		})
+	})
+})
+
+func renderIsolationMatrix(names []string, rows []string) string {
+	var lines []string
+	for i, row := range rows {
+		lines = append(lines, fmt.Sprintf("  %-22s %s", names[i], strings.Join(strings.Split(row, ""), " ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// killAndWait kills a listener and waits for it to exit, so that the next
+// cell of the matrix can bind the same port.
+func killAndWait(process garden.Process) {
+	process.Signal(garden.SignalKill)
+	process.Wait()
+}
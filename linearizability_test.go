@@ -0,0 +1,126 @@
+package garden_acceptance_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// historyOp is one completed call in a concurrent history. call describes it
+// for failure reports and input is what a sequentialModel reads. start and end
+// are ticks of a logical clock shared by every client, so an op that ends
+// before another starts is known to precede it.
+type historyOp struct {
+	client int
+	call   string
+	input  interface{}
+	output string
+	start  int64
+	end    int64
+}
+
+type history struct {
+	clock int64
+	mutex sync.Mutex
+	ops   []historyOp
+}
+
+// record runs f, which performs the call and returns its normalized result,
+// and appends it to the history.
+func (h *history) record(client int, call string, input interface{}, f func() string) string {
+	start := atomic.AddInt64(&h.clock, 1)
+	output := f()
+	end := atomic.AddInt64(&h.clock, 1)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.ops = append(h.ops, historyOp{client: client, call: call, input: input, output: output, start: start, end: end})
+	return output
+}
+
+func (h *history) String() string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return describeHistory(h.ops)
+}
+
+func describeHistory(ops []historyOp) string {
+	sorted := append([]historyOp{}, ops...)
+	sort.Sort(byStart(sorted))
+
+	var lines []string
+	for _, op := range sorted {
+		lines = append(lines, fmt.Sprintf("  [%4d, %4d] client %d: %s -> %s", op.start, op.end, op.client, op.call, op.output))
+	}
+	return strings.Join(lines, "\n")
+}
+
+type byStart []historyOp
+
+func (ops byStart) Len() int           { return len(ops) }
+func (ops byStart) Less(i, j int) bool { return ops[i].start < ops[j].start }
+func (ops byStart) Swap(i, j int)      { ops[i], ops[j] = ops[j], ops[i] }
+
+// sequentialModel is the specification a history is checked against. step
+// applies op to state and reports whether op's recorded output is one the
+// model allows; key must identify equivalent states.
+type sequentialModel struct {
+	init func() interface{}
+	step func(state interface{}, op historyOp) (interface{}, bool)
+	key  func(state interface{}) string
+}
+
+// isLinearizable searches for an order of ops that respects real time and
+// explains every output under model, in the style of Wing and Gong with
+// memoization of visited (linearized set, state) pairs. Histories are limited
+// to 64 ops.
+func isLinearizable(ops []historyOp, model sequentialModel) bool {
+	if len(ops) > 64 {
+		panic("isLinearizable supports at most 64 ops")
+	}
+
+	visited := map[string]bool{}
+	all := uint64(1)<<uint(len(ops)) - 1
+
+	var search func(done uint64, state interface{}) bool
+	search = func(done uint64, state interface{}) bool {
+		if done == all {
+			return true
+		}
+
+		memo := fmt.Sprintf("%x:%s", done, model.key(state))
+		if visited[memo] {
+			return false
+		}
+		visited[memo] = true
+
+		// an op may go next only if no pending op ended before it started
+		earliestEnd := int64(-1)
+		for i, op := range ops {
+			if done&(1<<uint(i)) == 0 && (earliestEnd < 0 || op.end < earliestEnd) {
+				earliestEnd = op.end
+			}
+		}
+
+		for i, op := range ops {
+			if done&(1<<uint(i)) != 0 || op.start > earliestEnd {
+				continue
+			}
+			if next, ok := model.step(state, op); ok && search(done|1<<uint(i), next) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return search(0, model.init())
+}
+
+func okOrError(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
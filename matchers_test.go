@@ -0,0 +1,246 @@
+package garden_acceptance_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/cloudfoundry-incubator/garden"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/format"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/types"
+)
+
+// processRunner builds and runs a process, keeping stdout and stderr apart so
+// that matchers can report both along with the spec:
+//
+//	Ω(runIn(container).As("alice").Command("touch", "/etc/x").Wait()).Should(ExitWith(1))
+type processRunner struct {
+	container garden.Container
+	spec      garden.ProcessSpec
+	stdin     io.Reader
+}
+
+type processResult struct {
+	Spec     garden.ProcessSpec
+	ExitCode int
+	Err      error
+	Stdout   *gbytes.Buffer
+	Stderr   *gbytes.Buffer
+}
+
+func runIn(container garden.Container) *processRunner {
+	return &processRunner{container: container, spec: garden.ProcessSpec{User: "root"}}
+}
+
+func (r *processRunner) As(user string) *processRunner {
+	r.spec.User = user
+	return r
+}
+
+func (r *processRunner) Command(path string, args ...string) *processRunner {
+	r.spec.Path = path
+	r.spec.Args = args
+	return r
+}
+
+// Shell runs script with sh -c.
+func (r *processRunner) Shell(script string) *processRunner {
+	return r.Command("sh", "-c", script)
+}
+
+func (r *processRunner) Env(env ...string) *processRunner {
+	r.spec.Env = append(r.spec.Env, env...)
+	return r
+}
+
+func (r *processRunner) Dir(dir string) *processRunner {
+	r.spec.Dir = dir
+	return r
+}
+
+func (r *processRunner) Stdin(stdin []byte) *processRunner {
+	r.stdin = bytes.NewReader(stdin)
+	return r
+}
+
+// TTY gives the process a terminal, which merges its stderr into stdout.
+func (r *processRunner) TTY() *processRunner {
+	r.spec.TTY = &garden.TTYSpec{}
+	return r
+}
+
+func (r *processRunner) Limits(limits garden.ResourceLimits) *processRunner {
+	r.spec.Limits = limits
+	return r
+}
+
+// Start runs the process without waiting for it. The result's buffers fill
+// as the process writes.
+func (r *processRunner) Start() (garden.Process, *processResult) {
+	result := &processResult{Spec: r.spec, Stdout: gbytes.NewBuffer(), Stderr: gbytes.NewBuffer()}
+
+	// recordedProcessIO, but with stderr kept apart
+	processIO := recordedProcessIO(result.Stdout)
+	processIO.Stderr = io.MultiWriter(result.Stderr, GinkgoWriter)
+	processIO.Stdin = r.stdin
+
+	process, err := r.container.Run(r.spec, processIO)
+	Ω(err).ShouldNot(HaveOccurred(), fmt.Sprintf("Error while running process with spec: %+v", r.spec))
+	return process, result
+}
+
+// Wait runs the process to completion.
+func (r *processRunner) Wait() *processResult {
+	process, result := r.Start()
+	result.ExitCode, result.Err = process.Wait()
+	return result
+}
+
+func (result *processResult) String() string {
+	return fmt.Sprintf("process %+v\nexit code: %d (err: %v)\nstdout:\n%s\nstderr:\n%s",
+		result.Spec, result.ExitCode, result.Err, result.Stdout.Contents(), result.Stderr.Contents())
+}
+
+// ExitWith succeeds if a *processResult exited with code.
+func ExitWith(code int) types.GomegaMatcher {
+	return &exitWithMatcher{code: code}
+}
+
+type exitWithMatcher struct {
+	code int
+}
+
+func (m *exitWithMatcher) Match(actual interface{}) (bool, error) {
+	result, err := toProcessResult("ExitWith", actual)
+	if err != nil {
+		return false, err
+	}
+	// a process whose exit code is unknown matches neither ExitWith nor its
+	// negation
+	if result.Err != nil {
+		return false, fmt.Errorf("ExitWith cannot tell the exit code of %s", result)
+	}
+	return result.ExitCode == m.code, nil
+}
+
+func (m *exitWithMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s\nto exit with %d", actual, m.code)
+}
+
+func (m *exitWithMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s\nnot to exit with %d", actual, m.code)
+}
+
+// HaveStdout succeeds if a *processResult's stdout matches expected, which
+// may be a matcher or a string to compare with Equal.
+func HaveStdout(expected interface{}) types.GomegaMatcher {
+	return &haveOutputMatcher{stream: "stdout", expected: expected}
+}
+
+// HaveStderr is HaveStdout for stderr.
+func HaveStderr(expected interface{}) types.GomegaMatcher {
+	return &haveOutputMatcher{stream: "stderr", expected: expected}
+}
+
+type haveOutputMatcher struct {
+	stream   string
+	expected interface{}
+
+	output string
+}
+
+func (m *haveOutputMatcher) Match(actual interface{}) (bool, error) {
+	result, err := toProcessResult("Have"+m.stream, actual)
+	if err != nil {
+		return false, err
+	}
+
+	output := result.Stdout
+	if m.stream == "stderr" {
+		output = result.Stderr
+	}
+	m.output = string(output.Contents())
+	return asMatcher(m.expected).Match(m.output)
+}
+
+func (m *haveOutputMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Unexpected %s from %s\n%s", m.stream, actual, asMatcher(m.expected).FailureMessage(m.output))
+}
+
+func (m *haveOutputMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Unexpected %s from %s\n%s", m.stream, actual, asMatcher(m.expected).NegatedFailureMessage(m.output))
+}
+
+// HaveProperty succeeds if a garden.Container has property key with a value
+// matching expected.
+func HaveProperty(key string, expected interface{}) types.GomegaMatcher {
+	return &containerMatcher{
+		description: fmt.Sprintf("property %q", key),
+		expected:    expected,
+		extract: func(container garden.Container) (interface{}, error) {
+			return container.Property(key)
+		},
+	}
+}
+
+// HaveContainerIP succeeds if a garden.Container's info reports an IP
+// matching expected.
+func HaveContainerIP(expected interface{}) types.GomegaMatcher {
+	return &containerMatcher{
+		description: "container IP",
+		expected:    expected,
+		extract: func(container garden.Container) (interface{}, error) {
+			info, err := container.Info()
+			return info.ContainerIP, err
+		},
+	}
+}
+
+type containerMatcher struct {
+	description string
+	expected    interface{}
+	extract     func(garden.Container) (interface{}, error)
+
+	value interface{}
+}
+
+func (m *containerMatcher) Match(actual interface{}) (bool, error) {
+	container, ok := actual.(garden.Container)
+	if !ok {
+		return false, fmt.Errorf("expected a garden.Container, got:\n%s", format.Object(actual, 1))
+	}
+
+	var err error
+	m.value, err = m.extract(container)
+	if err != nil {
+		return false, fmt.Errorf("cannot check container %s for %s: %s", container.Handle(), m.description, err)
+	}
+	return asMatcher(m.expected).Match(m.value)
+}
+
+func (m *containerMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Unexpected %s for container %s\n%s", m.description, actual.(garden.Container).Handle(), asMatcher(m.expected).FailureMessage(m.value))
+}
+
+func (m *containerMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Unexpected %s for container %s\n%s", m.description, actual.(garden.Container).Handle(), asMatcher(m.expected).NegatedFailureMessage(m.value))
+}
+
+func toProcessResult(matcher string, actual interface{}) (*processResult, error) {
+	result, ok := actual.(*processResult)
+	if !ok {
+		return nil, fmt.Errorf("%s expects a *processResult, got:\n%s", matcher, format.Object(actual, 1))
+	}
+	return result, nil
+}
+
+func asMatcher(expected interface{}) types.GomegaMatcher {
+	if matcher, ok := expected.(types.GomegaMatcher); ok {
+		return matcher
+	}
+	return Equal(expected)
+}
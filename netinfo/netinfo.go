@@ -0,0 +1,235 @@
+// Package netinfo describes the network configuration visible from inside a
+// container. The netinfo command prints it as JSON so that specs can decode it
+// instead of scraping ifconfig and route, whose output differs between rootfs
+// images.
+package netinfo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+type Info struct {
+	Hostname   string      `json:"hostname"`
+	Interfaces []Interface `json:"interfaces"`
+	Routes     []Route     `json:"routes"`
+	DNS        DNS         `json:"dns"`
+}
+
+type Interface struct {
+	Name      string    `json:"name"`
+	MTU       int       `json:"mtu"`
+	Flags     []string  `json:"flags"`
+	Addresses []Address `json:"addresses"`
+}
+
+type Address struct {
+	IP           string `json:"ip"`
+	PrefixLength int    `json:"prefix_length"`
+}
+
+type Route struct {
+	Destination string `json:"destination"`
+	Gateway     string `json:"gateway"`
+	Interface   string `json:"interface"`
+}
+
+type DNS struct {
+	Nameservers []string `json:"nameservers"`
+	Search      []string `json:"search"`
+}
+
+// ContainerInterface returns the first interface that isn't loopback.
+func (info Info) ContainerInterface() (Interface, bool) {
+	for _, iface := range info.Interfaces {
+		if !iface.HasFlag("loopback") {
+			return iface, true
+		}
+	}
+	return Interface{}, false
+}
+
+// DefaultGateway returns the gateway of the 0.0.0.0/0 route, if any.
+func (info Info) DefaultGateway() (string, bool) {
+	for _, route := range info.Routes {
+		if route.Destination == "0.0.0.0/0" {
+			return route.Gateway, true
+		}
+	}
+	return "", false
+}
+
+func (iface Interface) HasFlag(flag string) bool {
+	for _, f := range iface.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// IPv4 returns the interface's IPv4 addresses.
+func (iface Interface) IPv4() []Address {
+	return iface.addresses(func(ip net.IP) bool { return ip.To4() != nil })
+}
+
+// GlobalIPv6 returns the interface's globally routable IPv6 addresses.
+func (iface Interface) GlobalIPv6() []Address {
+	return iface.addresses(func(ip net.IP) bool { return ip.To4() == nil && ip.IsGlobalUnicast() })
+}
+
+func (iface Interface) addresses(include func(net.IP) bool) []Address {
+	var addresses []Address
+	for _, address := range iface.Addresses {
+		if ip := net.ParseIP(address.IP); ip != nil && include(ip) {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+func Collect() (Info, error) {
+	var info Info
+	var err error
+
+	info.Hostname, err = os.Hostname()
+	if err != nil {
+		return Info{}, err
+	}
+
+	info.Interfaces, err = interfaces()
+	if err != nil {
+		return Info{}, err
+	}
+
+	routes, err := os.Open("/proc/net/route")
+	if err != nil {
+		return Info{}, err
+	}
+	defer routes.Close()
+
+	info.Routes, err = ParseRoutes(routes)
+	if err != nil {
+		return Info{}, err
+	}
+
+	resolvConf, err := os.Open("/etc/resolv.conf")
+	if err != nil && !os.IsNotExist(err) {
+		return Info{}, err
+	}
+	if err == nil {
+		defer resolvConf.Close()
+
+		info.DNS, err = ParseResolvConf(resolvConf)
+		if err != nil {
+			return Info{}, err
+		}
+	}
+
+	return info, nil
+}
+
+func interfaces() ([]Interface, error) {
+	netInterfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Interface
+	for _, netInterface := range netInterfaces {
+		iface := Interface{Name: netInterface.Name, MTU: netInterface.MTU, Flags: []string{}, Addresses: []Address{}}
+		for _, flag := range strings.Split(netInterface.Flags.String(), "|") {
+			if flag != "" && flag != "0" {
+				iface.Flags = append(iface.Flags, flag)
+			}
+		}
+
+		addrs, err := netInterface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			prefixLength, _ := ipNet.Mask.Size()
+			iface.Addresses = append(iface.Addresses, Address{IP: ipNet.IP.String(), PrefixLength: prefixLength})
+		}
+
+		result = append(result, iface)
+	}
+	return result, nil
+}
+
+// ParseRoutes parses the IPv4 routing table in the format of /proc/net/route,
+// where addresses are little-endian hex.
+func ParseRoutes(r io.Reader) ([]Route, error) {
+	routes := []Route{}
+	scanner := bufio.NewScanner(r)
+	for line := 0; scanner.Scan(); line++ {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+		fields := strings.Fields(scanner.Text())
+		if line == 0 || len(fields) < 8 {
+			continue
+		}
+
+		destination, err := parseHexIP(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("route line %d: %s", line, err)
+		}
+		gateway, err := parseHexIP(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("route line %d: %s", line, err)
+		}
+		mask, err := parseHexIP(fields[7])
+		if err != nil {
+			return nil, fmt.Errorf("route line %d: %s", line, err)
+		}
+
+		prefixLength, _ := net.IPMask(mask).Size()
+		routes = append(routes, Route{
+			Destination: fmt.Sprintf("%s/%d", destination, prefixLength),
+			Gateway:     gateway.String(),
+			Interface:   fields[0],
+		})
+	}
+	return routes, scanner.Err()
+}
+
+func parseHexIP(field string) (net.IP, error) {
+	raw, err := hex.DecodeString(field)
+	if err != nil || len(raw) != net.IPv4len {
+		return nil, fmt.Errorf("invalid address %q", field)
+	}
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(raw))
+	return ip, nil
+}
+
+// ParseResolvConf extracts the nameserver and search entries of a
+// resolv.conf file.
+func ParseResolvConf(r io.Reader) (DNS, error) {
+	dns := DNS{Nameservers: []string{}, Search: []string{}}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+
+		switch fields[0] {
+		case "nameserver":
+			dns.Nameservers = append(dns.Nameservers, fields[1])
+		case "search", "domain":
+			dns.Search = append(dns.Search, fields[1:]...)
+		}
+	}
+	return dns, scanner.Err()
+}
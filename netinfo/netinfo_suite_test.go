@@ -0,0 +1,13 @@
+package netinfo_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestNetinfo(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Netinfo Suite")
+}
@@ -0,0 +1,74 @@
+package netinfo_test
+
+import (
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden-acceptance/netinfo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("netinfo", func() {
+	Describe("ParseRoutes", func() {
+		It("decodes little-endian addresses and masks", func() {
+			routes, err := netinfo.ParseRoutes(strings.NewReader(
+				"Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+					"wabc-1\t00000000\t0100020A\t0003\t0\t0\t0\t00000000\t0\t0\t0\n" +
+					"wabc-1\t0000020A\t00000000\t0001\t0\t0\t0\t00FFFFFF\t0\t0\t0\n",
+			))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(routes).Should(Equal([]netinfo.Route{
+				{Destination: "0.0.0.0/0", Gateway: "10.2.0.1", Interface: "wabc-1"},
+				{Destination: "10.2.0.0/24", Gateway: "0.0.0.0", Interface: "wabc-1"},
+			}))
+		})
+
+		It("fails on malformed addresses", func() {
+			_, err := netinfo.ParseRoutes(strings.NewReader(
+				"Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+					"wabc-1\tnothex\t0100020A\t0003\t0\t0\t0\t00000000\t0\t0\t0\n",
+			))
+			Ω(err).Should(MatchError(`route line 1: invalid address "nothex"`))
+		})
+	})
+
+	Describe("ParseResolvConf", func() {
+		It("collects nameservers and search domains, ignoring comments", func() {
+			dns, err := netinfo.ParseResolvConf(strings.NewReader(
+				"# generated\nnameserver 10.0.0.2\n; nameserver 1.1.1.1\nsearch a.example b.example\nnameserver 8.8.8.8\n",
+			))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(dns.Nameservers).Should(Equal([]string{"10.0.0.2", "8.8.8.8"}))
+			Ω(dns.Search).Should(Equal([]string{"a.example", "b.example"}))
+		})
+	})
+
+	Describe("Info", func() {
+		info := netinfo.Info{
+			Interfaces: []netinfo.Interface{
+				{Name: "lo", Flags: []string{"up", "loopback"}},
+				{Name: "wabc-1", Flags: []string{"up", "broadcast"}, Addresses: []netinfo.Address{
+					{IP: "10.2.0.3", PrefixLength: 24},
+					{IP: "fe80::1", PrefixLength: 64},
+					{IP: "2001:db8::3", PrefixLength: 64},
+				}},
+			},
+			Routes: []netinfo.Route{{Destination: "0.0.0.0/0", Gateway: "10.2.0.1", Interface: "wabc-1"}},
+		}
+
+		It("finds the container interface and its addresses", func() {
+			iface, ok := info.ContainerInterface()
+			Ω(ok).Should(BeTrue())
+			Ω(iface.Name).Should(Equal("wabc-1"))
+			Ω(iface.IPv4()).Should(Equal([]netinfo.Address{{IP: "10.2.0.3", PrefixLength: 24}}))
+			Ω(iface.GlobalIPv6()).Should(Equal([]netinfo.Address{{IP: "2001:db8::3", PrefixLength: 64}}))
+		})
+
+		It("finds the default gateway", func() {
+			gateway, ok := info.DefaultGateway()
+			Ω(ok).Should(BeTrue())
+			Ω(gateway).Should(Equal("10.2.0.1"))
+		})
+	})
+})
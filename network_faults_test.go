@@ -0,0 +1,114 @@
+package garden_acceptance_test
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-acceptance/faultproxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("network faults between client and server", func() {
+	var container garden.Container
+
+	BeforeEach(func() {
+		container = createContainer(gardenClient, garden.ContainerSpec{})
+	})
+
+	type exit struct {
+		status int
+		err    error
+	}
+
+	// waitFor fails the spec if Wait hangs rather than returning
+	waitFor := func(process garden.Process) exit {
+		exited := make(chan exit, 1)
+		go func() {
+			status, err := process.Wait()
+			exited <- exit{status, err}
+		}()
+
+		var e exit
+		Eventually(exited, "30s").Should(Receive(&e))
+		return e
+	}
+
+	It("runs processes and streams their output under latency", func() {
+		gardenProxy.SetFaults(faultproxy.Faults{Latency: 20 * time.Millisecond})
+
+		result := runIn(container).Shell("for i in 1 2 3; do echo $i; sleep 0.1; done").Wait()
+		Ω(result).Should(ExitWith(0))
+		Ω(result).Should(HaveStdout("1\n2\n3\n"))
+	})
+
+	It("keeps a process running when its Run stream is reset, and lets it be attached to", func() {
+		gardenProxy.SetFaults(faultproxy.Faults{ResetProcessStreamsAfter: 500 * time.Millisecond})
+
+		process, _ := runIn(container).Shell("sleep 3; echo done; exit 42").Start()
+		Ω(waitFor(process).err).Should(HaveOccurred())
+
+		gardenProxy.SetFaults(faultproxy.Faults{})
+
+		stdout := gbytes.NewBuffer()
+		attached, err := container.Attach(process.ID(), garden.ProcessIO{Stdout: stdout, Stderr: GinkgoWriter})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(waitFor(attached)).Should(Equal(exit{status: 42}))
+		Ω(stdout).Should(gbytes.Say("done"))
+	})
+
+	It("keeps a process running when an Attach stream is reset", func() {
+		process, _ := runIn(container).Shell("sleep 3; exit 42").Start()
+
+		gardenProxy.SetFaults(faultproxy.Faults{ResetProcessStreamsAfter: 500 * time.Millisecond})
+		attached, err := container.Attach(process.ID(), silentProcessIO)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(waitFor(attached).err).Should(HaveOccurred())
+
+		gardenProxy.SetFaults(faultproxy.Faults{})
+		attached, err = container.Attach(process.ID(), silentProcessIO)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(waitFor(attached)).Should(Equal(exit{status: 42}))
+	})
+
+	It("fails Wait, rather than hanging, when the connection drops mid-output", func() {
+		gardenProxy.SetFaults(faultproxy.Faults{DropAfterBytes: 16 * 1024})
+
+		process, _ := runIn(container).Shell("head -c 1048576 /dev/zero; sleep 1").Start()
+		Ω(waitFor(process).err).Should(HaveOccurred())
+
+		gardenProxy.SetFaults(faultproxy.Faults{})
+		Ω(runIn(container).Command("true").Wait()).Should(ExitWith(0))
+	})
+
+	It("fails StreamIn when the connection drops mid-upload and accepts a retry", func() {
+		content := bytes.Repeat([]byte("x"), 1024*1024)
+
+		gardenProxy.SetFaults(faultproxy.Faults{DropAfterBytes: 64 * 1024})
+		err := container.StreamIn(garden.StreamInSpec{Path: "/tmp", User: "root", TarFile: tarFile("upload", content)})
+		Ω(err).Should(HaveOccurred())
+
+		gardenProxy.SetFaults(faultproxy.Faults{})
+		err = container.StreamIn(garden.StreamInSpec{Path: "/tmp", User: "root", TarFile: tarFile("upload", content)})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(runIn(container).Command("wc", "-c", "/tmp/upload").Wait()).Should(HaveStdout("1048576 /tmp/upload\n"))
+	})
+
+	It("reports truncated responses as errors instead of hanging", func() {
+		gardenProxy.SetFaults(faultproxy.Faults{TruncateResponsesAfterBytes: 20})
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := container.Info()
+			errs <- err
+		}()
+		Eventually(errs, "30s").Should(Receive(HaveOccurred()))
+
+		gardenProxy.SetFaults(faultproxy.Faults{})
+		_, err := container.Info()
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+})
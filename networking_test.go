@@ -2,11 +2,17 @@ package garden_acceptance_test
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-acceptance/gardentest"
+	"github.com/cloudfoundry-incubator/garden-acceptance/netinfo"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -95,7 +101,7 @@ var _ = Describe("networking", func() {
 
 	It("can open outbound ICMP connections (#85601268)", func() {
 		container := createContainer(gardenClient, garden.ContainerSpec{})
-		Ω(container.NetOut(pingRule("8.8.8.8"))).Should(Succeed())
+		Ω(container.NetOut(gardentest.PingRule("8.8.8.8"))).Should(Succeed())
 		buffer := gbytes.NewBuffer()
 		process, err := container.Run(garden.ProcessSpec{
 			User: "root",
@@ -109,46 +115,46 @@ var _ = Describe("networking", func() {
 		Ω(buffer).ShouldNot(gbytes.Say("100% packet loss"))
 	})
 
-	// TODO: Work out how to check this on the host
-	PIt("logs outbound TCP connections (#90216342, #82554270)", func() {
+	It("logs outbound TCP connections (#90216342, #82554270)", func() {
+		requireCapabilities(hostSyslog, containerPathOnHost)
+
 		container := createContainer(gardenClient, garden.ContainerSpec{Handle: "Unique"})
-		Ω(container.NetOut(tcpRule("93.184.216.34", 80))).Should(Succeed())
+		Ω(container.NetOut(gardentest.TCPRule("93.184.216.34", 80))).Should(Succeed())
 
-		_, _, err := runCommand("sudo sh -c 'echo > /var/log/syslog'")
+		// read only what is logged from here on, rather than clearing the
+		// host's syslog
+		size, _, err := gardentest.RunCommand("sudo stat -c %s /var/log/syslog")
+		Ω(err).ShouldNot(HaveOccurred())
+		offset, err := strconv.Atoi(strings.TrimSpace(size))
 		Ω(err).ShouldNot(HaveOccurred())
+
 		stdout := runInContainerSuccessfully(container, "wget -qO- http://example.com")
 		Ω(stdout).Should(ContainSubstring("Example Domain"))
 
-		stdout, _, err = runCommand("sudo cat /var/log/syslog")
+		stdout, _, err = gardentest.RunCommand(fmt.Sprintf("sudo tail -c +%d /var/log/syslog", offset+1))
 		Ω(err).ShouldNot(HaveOccurred())
 		Ω(stdout).Should(ContainSubstring("Unique"))
 		Ω(stdout).Should(ContainSubstring("DST=93.184.216.34"))
 	})
 
 	It("respects network option to set subnet for a container (#75464982)", func() {
-		container := createContainer(gardenClient, garden.ContainerSpec{Privileged: true, Network: "10.2.0.3/24"})
-		buffer := gbytes.NewBuffer()
-		process, err := container.Run(garden.ProcessSpec{
-			User: "root",
-			Path: "ifconfig",
-		}, recordedProcessIO(buffer))
-		Ω(err).ShouldNot(HaveOccurred())
-		Ω(process.Wait()).Should(Equal(0))
+		requireCapabilities(privilegedContainers)
 
-		Ω(buffer).Should(gbytes.Say("inet addr:10.2.0.3"))
-		Ω(buffer).Should(gbytes.Say("Bcast:0.0.0.0  Mask:255.255.255.0"))
+		container := createContainer(gardenClient, withProbes(garden.ContainerSpec{Privileged: true, Network: "10.2.0.3/24"}))
+		info := containerNetInfo(container)
 
-		buffer = gbytes.NewBuffer()
-		process, err = container.Run(garden.ProcessSpec{
-			User: "root",
-			Path: "route",
-		}, recordedProcessIO(buffer))
-		Ω(err).ShouldNot(HaveOccurred())
-		Ω(process.Wait()).Should(Equal(0))
-		Ω(buffer).Should(gbytes.Say("default\\s+10.2.0.1"))
+		iface, ok := info.ContainerInterface()
+		Ω(ok).Should(BeTrue(), "Container has no network interface")
+		Ω(iface.IPv4()).Should(ConsistOf(netinfo.Address{IP: "10.2.0.3", PrefixLength: 24}))
+
+		gateway, ok := info.DefaultGateway()
+		Ω(ok).Should(BeTrue(), "Container has no default route")
+		Ω(gateway).Should(Equal("10.2.0.1"))
 	})
 
 	It("allows containers to talk to each other (#75464982)", func() {
+		requireCapabilities(privilegedContainers)
+
 		container := createContainer(gardenClient, garden.ContainerSpec{Privileged: true, Network: "10.2.0.0/30"})
 		container2 := createContainer(gardenClient, garden.ContainerSpec{Network: "10.3.0.0/30"})
 		info, err := container2.Info()
@@ -166,9 +172,11 @@ var _ = Describe("networking", func() {
 	})
 
 	It("doesn't destroy routes when destroying container (Bug #83656106)", func() {
+		requireCapabilities(privilegedContainers)
+
 		container1 := createContainer(gardenClient, garden.ContainerSpec{Privileged: true, Network: "10.2.0.0/24"})
 		container2 := createContainer(gardenClient, garden.ContainerSpec{Privileged: true, Network: "10.3.0.0/24"})
-		Ω(container2.NetOut(pingRule("8.8.8.8"))).Should(Succeed())
+		Ω(container2.NetOut(gardentest.PingRule("8.8.8.8"))).Should(Succeed())
 
 		gardenClient.Destroy(container1.Handle())
 
@@ -193,22 +201,18 @@ var _ = Describe("networking", func() {
 	})
 
 	It("should allow configuration of MTU (#80221576)", func() {
-		container, err := gardenClient.Create(garden.ContainerSpec{
+		requireCapabilities(dockerRootFS)
+
+		container := createContainer(gardenClient, withProbes(garden.ContainerSpec{
 			RootFSPath: "docker:///onsi/grace-busybox",
-		})
-		Ω(err).ShouldNot(HaveOccurred())
+		}))
 
-		buffer := gbytes.NewBuffer()
-		process, err := container.Run(garden.ProcessSpec{
-			User: "root",
-			Path: "ifconfig",
-		}, recordedProcessIO(buffer))
-		Ω(err).ShouldNot(HaveOccurred())
-		Ω(process.Wait()).Should(Equal(0))
-		Ω(buffer).Should(gbytes.Say("MTU:1499"))
+		iface, ok := containerNetInfo(container).ContainerInterface()
+		Ω(ok).Should(BeTrue(), "Container has no network interface")
+		Ω(iface.MTU).Should(Equal(1499))
 
 		// TODO: Work out how to check on the host end
-		// stdout, _, err = runCommand("/sbin/ifconfig")
+		// stdout, _, err = gardentest.RunCommand("/sbin/ifconfig")
 		// Ω(err).ShouldNot(HaveOccurred())
 		// Ω(stdout).Should(ContainSubstring("MTU:1499"))
 	})
@@ -254,11 +258,30 @@ var _ = Describe("networking", func() {
 	})
 })
 
-func tcpRule(ip string, port uint16) garden.NetOutRule {
-	return garden.NetOutRule{
-		Protocol: garden.ProtocolTCP,
-		Networks: []garden.IPRange{garden.IPRangeFromIP(net.ParseIP(ip))},
-		Ports:    []garden.PortRange{garden.PortRangeFromPort(port)},
-		Log:      true,
+func containerNetInfo(container garden.Container) netinfo.Info {
+	var info netinfo.Info
+	runProbe(container, &info, "netinfo")
+	return info
+}
+
+// probeNetInfo is containerNetInfo for callers that must not fail the spec,
+// such as scenarios being shrunk.
+func probeNetInfo(container garden.Container) (netinfo.Info, error) {
+	var info netinfo.Info
+	stdout := gbytes.NewBuffer()
+	process, err := container.Run(garden.ProcessSpec{
+		User: "root",
+		Path: filepath.Join(probesPath, "netinfo"),
+	}, garden.ProcessIO{Stdout: stdout, Stderr: GinkgoWriter})
+	if err != nil {
+		return info, err
+	}
+	status, err := process.Wait()
+	if err != nil {
+		return info, err
+	}
+	if status != 0 {
+		return info, fmt.Errorf("netinfo exited with %d", status)
 	}
+	return info, json.Unmarshal(stdout.Contents(), &info)
 }
@@ -0,0 +1,117 @@
+package garden_acceptance_test
+
+import (
+	"io"
+
+	"github.com/cloudfoundry-incubator/garden"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+// failureOf runs r expecting it to fail and returns why: the error from Run,
+// or the stderr of a process that started and exited non-zero.
+func failureOf(r *processRunner) string {
+	stderr := gbytes.NewBuffer()
+	process, err := r.container.Run(r.spec, garden.ProcessIO{
+		Stdout: GinkgoWriter,
+		Stderr: io.MultiWriter(stderr, GinkgoWriter),
+	})
+	if err != nil {
+		return err.Error()
+	}
+
+	exitCode, err := process.Wait()
+	if err != nil {
+		return err.Error()
+	}
+	Ω(exitCode).ShouldNot(Equal(0), "Process with spec %+v succeeded", r.spec)
+	return string(stderr.Contents())
+}
+
+var _ = Describe("process resolution", func() {
+	var container garden.Container
+
+	BeforeEach(func() {
+		container = createContainer(gardenClient, garden.ContainerSpec{})
+	})
+
+	Describe("the working directory", func() {
+		It("is the user's home by default", func() {
+			Ω(runIn(container).Command("pwd").Wait()).Should(HaveStdout("/root\n"))
+			Ω(runIn(container).As("alice").Command("pwd").Wait()).Should(HaveStdout("/home/alice\n"))
+		})
+
+		It("is Dir when given", func() {
+			Ω(runIn(container).As("alice").Dir("/tmp").Command("pwd").Wait()).Should(HaveStdout("/tmp\n"))
+		})
+
+		It("is where relative file arguments are found", func() {
+			Ω(runIn(container).Dir("/etc").Command("cat", "passwd").Wait()).Should(HaveStdout(ContainSubstring("alice:")))
+		})
+
+		It("fails with a message naming a Dir that does not exist", func() {
+			Ω(failureOf(runIn(container).Dir("/does-not-exist").Command("pwd"))).
+				Should(ContainSubstring("chdir /does-not-exist: no such file or directory"))
+		})
+	})
+
+	Describe("the path", func() {
+		It("finds relative paths on the user's PATH", func() {
+			Ω(runIn(container).Command("ls", "/").Wait()).Should(ExitWith(0))
+			Ω(runIn(container).As("alice").Command("ls", "/").Wait()).Should(ExitWith(0))
+		})
+
+		It("uses a PATH set in the process's env", func() {
+			Ω(runIn(container).Shell("mkdir -p /tmp/bin && printf '#!/bin/sh\\necho hello\\n' > /tmp/bin/hello && chmod +x /tmp/bin/hello").Wait()).Should(ExitWith(0))
+
+			result := runIn(container).Env("PATH=/tmp/bin:/bin:/usr/bin").Command("hello").Wait()
+			Ω(result).Should(ExitWith(0))
+			Ω(result).Should(HaveStdout("hello\n"))
+		})
+
+		It("uses the PATH from a docker image's ENV", func() {
+			requireCapabilities(dockerRootFS)
+			golang := createContainer(gardenClient, garden.ContainerSpec{RootFSPath: "docker:///golang#1.4"})
+
+			Ω(runIn(golang).Shell("echo $PATH").Wait()).Should(HaveStdout(ContainSubstring("/usr/src/go/bin")))
+			Ω(runIn(golang).Command("go", "version").Wait()).Should(HaveStdout(ContainSubstring("go1.4")))
+		})
+
+		It("fails with a message naming an absolute path that does not exist", func() {
+			Ω(failureOf(runIn(container).Command("/bin/does-not-exist"))).
+				Should(ContainSubstring(`exec: "/bin/does-not-exist": stat /bin/does-not-exist: no such file or directory`))
+		})
+
+		It("fails with a message naming a command that is not on the PATH", func() {
+			Ω(failureOf(runIn(container).Command("does-not-exist"))).
+				Should(ContainSubstring(`exec: "does-not-exist": executable file not found in $PATH`))
+		})
+	})
+
+	Describe("the user", func() {
+		for _, user := range []struct{ name, home string }{
+			{"root", "/root"},
+			{"alice", "/home/alice"},
+			{"bob", "/home/bob"},
+		} {
+			user := user
+
+			It("sets HOME and USER for "+user.name, func() {
+				result := runIn(container).As(user.name).Shell(`echo "$HOME $USER"`).Wait()
+				Ω(result).Should(HaveStdout(user.home + " " + user.name + "\n"))
+			})
+		}
+
+		It("lets the process's env override HOME and USER", func() {
+			result := runIn(container).As("alice").Env("HOME=/tmp", "USER=someone").Shell(`echo "$HOME $USER"`).Wait()
+			Ω(result).Should(HaveStdout("/tmp someone\n"))
+		})
+
+		It("fails with a message naming a user that does not exist", func() {
+			Ω(failureOf(runIn(container).As("nobody-by-that-name").Command("true"))).
+				Should(ContainSubstring("failed to lookup user nobody-by-that-name"))
+		})
+	})
+})
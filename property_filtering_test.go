@@ -0,0 +1,125 @@
+package garden_acceptance_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("filtering containers by property", func() {
+	filter := func(properties garden.Properties) []string {
+		containers, err := gardenClient.Containers(properties)
+		Ω(err).ShouldNot(HaveOccurred())
+		return handlesOf(containers)
+	}
+
+	create := func(handle string, properties garden.Properties) {
+		createContainer(gardenClient, garden.ContainerSpec{Handle: handle, Properties: properties})
+	}
+
+	It("returns every container for an empty filter", func() {
+		create("a", garden.Properties{"foo": "bar"})
+		create("b", nil)
+
+		Ω(filter(garden.Properties{})).Should(ConsistOf("a", "b"))
+		Ω(filter(nil)).Should(ConsistOf("a", "b"))
+	})
+
+	It("requires every key of a multi-key filter to match", func() {
+		create("a", garden.Properties{"team": "x", "env": "prod"})
+		create("b", garden.Properties{"team": "x", "env": "staging"})
+		create("c", garden.Properties{"team": "x"})
+		create("d", garden.Properties{"env": "prod"})
+
+		Ω(filter(garden.Properties{"team": "x", "env": "prod"})).Should(ConsistOf("a"))
+		Ω(filter(garden.Properties{"team": "x"})).Should(ConsistOf("a", "b", "c"))
+		Ω(filter(garden.Properties{"team": "x", "env": "dev"})).Should(BeEmpty())
+	})
+
+	It("treats an empty-string value as a value, not as a wildcard or absence", func() {
+		create("empty", garden.Properties{"foo": ""})
+		create("set", garden.Properties{"foo": "bar"})
+		create("missing", nil)
+
+		Ω(filter(garden.Properties{"foo": ""})).Should(ConsistOf("empty"))
+	})
+
+	It("matches unicode keys and values exactly", func() {
+		create("unicode", garden.Properties{"ключ": "値", "emoji-☃": "❄"})
+		create("ascii", garden.Properties{"key": "value"})
+
+		Ω(filter(garden.Properties{"ключ": "値"})).Should(ConsistOf("unicode"))
+		Ω(filter(garden.Properties{"emoji-☃": "❄"})).Should(ConsistOf("unicode"))
+		Ω(filter(garden.Properties{"ключ": "值"})).Should(BeEmpty())
+	})
+
+	It("matches large values", func() {
+		large := strings.Repeat("0123456789abcdef", 4096)
+		create("large", garden.Properties{"blob": large})
+		create("almost", garden.Properties{"blob": large[:len(large)-1]})
+
+		Ω(filter(garden.Properties{"blob": large})).Should(ConsistOf("large"))
+	})
+
+	It("filters many containers at once", func() {
+		var evens []string
+		for i := 0; i < 20; i++ {
+			handle := fmt.Sprintf("container-%d", i)
+			parity := "odd"
+			if i%2 == 0 {
+				parity = "even"
+				evens = append(evens, handle)
+			}
+			create(handle, garden.Properties{"parity": parity, "index": fmt.Sprintf("%d", i)})
+		}
+
+		Ω(filter(garden.Properties{"parity": "even"})).Should(ConsistOf(evens))
+		Ω(filter(garden.Properties{"parity": "even", "index": "4"})).Should(ConsistOf("container-4"))
+		Ω(filter(garden.Properties{"parity": "odd", "index": "4"})).Should(BeEmpty())
+	})
+
+	It("reflects SetProperty and RemoveProperty", func() {
+		container := createContainer(gardenClient, garden.ContainerSpec{Handle: "changing"})
+		create("other", garden.Properties{"foo": "bar"})
+
+		Ω(container.SetProperty("foo", "bar")).Should(Succeed())
+		Ω(filter(garden.Properties{"foo": "bar"})).Should(ConsistOf("changing", "other"))
+
+		Ω(container.SetProperty("foo", "baz")).Should(Succeed())
+		Ω(filter(garden.Properties{"foo": "bar"})).Should(ConsistOf("other"))
+		Ω(filter(garden.Properties{"foo": "baz"})).Should(ConsistOf("changing"))
+
+		Ω(container.RemoveProperty("foo")).Should(Succeed())
+		Ω(filter(garden.Properties{"foo": "baz"})).Should(BeEmpty())
+	})
+
+	It("survives a restart", func() {
+		requireCapabilities(gardenRestart)
+
+		container := createContainer(gardenClient, garden.ContainerSpec{
+			Handle:     "persistent",
+			Properties: garden.Properties{"foo": "bar", "team": "x"},
+		})
+		create("other", garden.Properties{"foo": "bar"})
+		Ω(container.SetProperty("added", "later")).Should(Succeed())
+		Ω(container.RemoveProperty("team")).Should(Succeed())
+
+		restartGarden()
+
+		Ω(filter(garden.Properties{"foo": "bar"})).Should(ConsistOf("persistent", "other"))
+		Ω(filter(garden.Properties{"added": "later"})).Should(ConsistOf("persistent"))
+		Ω(filter(garden.Properties{"team": "x"})).Should(BeEmpty())
+	})
+})
+
+func handlesOf(containers []garden.Container) []string {
+	handles := []string{}
+	for _, container := range containers {
+		handles = append(handles, container.Handle())
+	}
+	return handles
+}
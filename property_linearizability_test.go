@@ -0,0 +1,219 @@
+package garden_acceptance_test
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("concurrent property operations", func() {
+	const containerCount = 3
+	const clients = 6
+	const opsPerClient = 8
+
+	It("are linearizable, including Containers filters", func() {
+		seed := GinkgoRandomSeed()
+
+		var containers []garden.Container
+		for i := 0; i < containerCount; i++ {
+			containers = append(containers, createContainer(gardenClient, garden.ContainerSpec{}))
+		}
+
+		h := &history{}
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for client := 0; client < clients; client++ {
+			wg.Add(1)
+			go func(client int, r *rand.Rand) {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				<-start
+				for i := 0; i < opsPerClient; i++ {
+					propertyOperation(h, client, r, containers)
+				}
+			}(client, rand.New(rand.NewSource(seed*clients+int64(client))))
+		}
+		close(start)
+		wg.Wait()
+
+		// one model over every container, because a Containers call reads
+		// all of them at once
+		var handles []string
+		for _, container := range containers {
+			handles = append(handles, container.Handle())
+		}
+		Ω(isLinearizable(h.ops, propertyModel(handles))).Should(BeTrue(), fmt.Sprintf(
+			"property history (seed %d) has no sequential explanation:\n%s",
+			seed, describeHistory(h.ops),
+		))
+	})
+})
+
+type propertyCall struct {
+	kind   string
+	handle string
+	key    string
+	value  string
+}
+
+func propertyOperation(h *history, client int, r *rand.Rand, containers []garden.Container) {
+	var handles []string
+	for _, c := range containers {
+		handles = append(handles, c.Handle())
+	}
+
+	container := containers[r.Intn(len(containers))]
+	key := fmt.Sprintf("k%d", r.Intn(3))
+	value := fmt.Sprintf("v%d", r.Intn(3))
+
+	switch r.Intn(5) {
+	case 0, 1:
+		call := propertyCall{kind: "set", handle: container.Handle(), key: key, value: value}
+		h.record(client, fmt.Sprintf("%s SetProperty(%s, %s)", container.Handle(), key, value), call, func() string {
+			return okOrError(container.SetProperty(key, value))
+		})
+	case 2:
+		call := propertyCall{kind: "remove", handle: container.Handle(), key: key}
+		h.record(client, fmt.Sprintf("%s RemoveProperty(%s)", container.Handle(), key), call, func() string {
+			return okOrError(container.RemoveProperty(key))
+		})
+	case 3:
+		call := propertyCall{kind: "get", handle: container.Handle(), key: key}
+		h.record(client, fmt.Sprintf("%s Property(%s)", container.Handle(), key), call, func() string {
+			value, err := container.Property(key)
+			if err != nil {
+				return "error"
+			}
+			return value
+		})
+	case 4:
+		if r.Intn(2) == 0 {
+			call := propertyCall{kind: "all", handle: container.Handle()}
+			h.record(client, fmt.Sprintf("%s Properties()", container.Handle()), call, func() string {
+				properties, err := container.Properties()
+				if err != nil {
+					return "error"
+				}
+				return describeProperties(properties)
+			})
+			return
+		}
+
+		call := propertyCall{kind: "filter", key: key, value: value}
+		h.record(client, fmt.Sprintf("Containers(%s=%s)", key, value), call, func() string {
+			filtered, err := gardenClient.Containers(garden.Properties{key: value})
+			if err != nil {
+				return "error"
+			}
+			// containers from other specs may match too; only this
+			// spec's are in the model
+			var matched []string
+			for _, c := range filtered {
+				if containsString(handles, c.Handle()) {
+					matched = append(matched, c.Handle())
+				}
+			}
+			sort.Strings(matched)
+			return strings.Join(matched, ",")
+		})
+	}
+}
+
+// propertyModel holds the properties of every container in handles, so that
+// a Containers filter is checked against all of them at the same instant.
+func propertyModel(handles []string) sequentialModel {
+	return sequentialModel{
+		init: func() interface{} {
+			state := map[string]garden.Properties{}
+			for _, handle := range handles {
+				state[handle] = garden.Properties{}
+			}
+			return state
+		},
+		key: func(s interface{}) string {
+			state := s.(map[string]garden.Properties)
+			var parts []string
+			for _, handle := range handles {
+				parts = append(parts, handle+describeProperties(state[handle]))
+			}
+			return strings.Join(parts, ";")
+		},
+		step: func(s interface{}, op historyOp) (interface{}, bool) {
+			state := s.(map[string]garden.Properties)
+			call := op.input.(propertyCall)
+
+			if call.kind == "filter" {
+				var matched []string
+				for _, handle := range handles {
+					if state[handle][call.key] == call.value {
+						matched = append(matched, handle)
+					}
+				}
+				sort.Strings(matched)
+				return state, op.output == strings.Join(matched, ",")
+			}
+
+			properties, ok := stepProperties(state[call.handle], call, op.output)
+			if !ok {
+				return state, false
+			}
+			next := map[string]garden.Properties{}
+			for handle, p := range state {
+				next[handle] = p
+			}
+			next[call.handle] = properties
+			return next, true
+		},
+	}
+}
+
+// stepProperties applies a call on one container to its properties and
+// reports whether output is one the call could have returned.
+func stepProperties(state garden.Properties, call propertyCall, output string) (garden.Properties, bool) {
+	switch call.kind {
+	case "set":
+		next := garden.Properties{}
+		for k, v := range state {
+			next[k] = v
+		}
+		next[call.key] = call.value
+		return next, output == "ok"
+	case "remove":
+		if _, ok := state[call.key]; !ok {
+			return state, output == "error"
+		}
+		next := garden.Properties{}
+		for k, v := range state {
+			if k != call.key {
+				next[k] = v
+			}
+		}
+		return next, output == "ok"
+	case "get":
+		value, ok := state[call.key]
+		if !ok {
+			return state, output == "error"
+		}
+		return state, output == value
+	case "all":
+		return state, output == describeProperties(state)
+	}
+	return state, false
+}
+
+func describeProperties(properties garden.Properties) string {
+	var pairs []string
+	for k, v := range properties {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
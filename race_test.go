@@ -0,0 +1,134 @@
+package garden_acceptance_test
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("concurrent operations on one handle", func() {
+	const rounds = 10
+	const clients = 4
+	const opsPerClient = 5
+
+	It("are linearizable", func() {
+		portPoolSize := envInt("GARDEN_ACCEPTANCE_PORT_POOL_SIZE", 5)
+
+		for round := 0; round < rounds; round++ {
+			seed := GinkgoRandomSeed() + int64(round)
+			handle := fmt.Sprintf("race-%d", seed)
+
+			// every incarnation of the handle shares the same container
+			// object, so calls on it never need a Lookup first
+			container := createContainer(gardenClient, garden.ContainerSpec{Handle: handle})
+
+			h := &history{}
+			start := make(chan struct{})
+			var wg sync.WaitGroup
+			for client := 0; client < clients; client++ {
+				wg.Add(1)
+				go func(client int, r *rand.Rand) {
+					defer GinkgoRecover()
+					defer wg.Done()
+
+					<-start
+					for i := 0; i < opsPerClient; i++ {
+						raceOperation(h, client, r, container)
+					}
+				}(client, rand.New(rand.NewSource(seed*clients+int64(client))))
+			}
+			close(start)
+			wg.Wait()
+
+			Ω(isLinearizable(h.ops, handleModel(portPoolSize))).Should(BeTrue(), fmt.Sprintf(
+				"history for %s (seed %d) has no sequential explanation:\n%s", handle, seed, h,
+			))
+
+			destroyAllContainers(gardenClient)
+		}
+	})
+})
+
+func raceOperation(h *history, client int, r *rand.Rand, container garden.Container) {
+	handle := container.Handle()
+
+	switch r.Intn(6) {
+	case 0:
+		h.record(client, "Create", "Create", func() string {
+			_, err := gardenClient.Create(garden.ContainerSpec{Handle: handle})
+			return okOrError(err)
+		})
+	case 1:
+		h.record(client, "Destroy", "Destroy", func() string {
+			return okOrError(gardenClient.Destroy(handle))
+		})
+	case 2:
+		h.record(client, "Lookup", "Lookup", func() string {
+			_, err := gardenClient.Lookup(handle)
+			return okOrError(err)
+		})
+	case 3:
+		value := fmt.Sprintf("%d", r.Intn(100))
+		h.record(client, "SetProperty(key, "+value+")", "SetProperty", func() string {
+			return okOrError(container.SetProperty("key", value))
+		})
+	case 4:
+		h.record(client, "NetIn", "NetIn", func() string {
+			_, _, err := container.NetIn(0, 0)
+			return okOrError(err)
+		})
+	case 5:
+		h.record(client, "Run", "Run", func() string {
+			process, err := container.Run(garden.ProcessSpec{User: "root", Path: "true"}, silentProcessIO)
+			if err == nil {
+				go process.Wait()
+			}
+			return okOrError(err)
+		})
+	}
+}
+
+type handleState struct {
+	exists bool
+	ports  int
+}
+
+// handleModel describes a single handle: calls succeed only while a
+// container exists, and NetIn also fails once the port pool is used up.
+func handleModel(portPoolSize int) sequentialModel {
+	return sequentialModel{
+		init: func() interface{} { return handleState{exists: true} },
+		key:  func(state interface{}) string { return fmt.Sprintf("%+v", state) },
+		step: func(s interface{}, op historyOp) (interface{}, bool) {
+			state := s.(handleState)
+			switch op.input.(string) {
+			case "Create":
+				if state.exists {
+					return state, op.output == "error"
+				}
+				return handleState{exists: true}, op.output == "ok"
+			case "Destroy":
+				if !state.exists {
+					return state, op.output == "error"
+				}
+				return handleState{}, op.output == "ok"
+			case "NetIn":
+				if !state.exists || state.ports >= portPoolSize {
+					return state, op.output == "error"
+				}
+				state.ports++
+				return state, op.output == "ok"
+			default:
+				if !state.exists {
+					return state, op.output == "error"
+				}
+				return state, op.output == "ok"
+			}
+		},
+	}
+}
@@ -0,0 +1,72 @@
+package recorder
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden/client"
+	"github.com/cloudfoundry-incubator/garden/client/connection"
+)
+
+// Backend serves a recording from a Garden server. It answers through a
+// client on a Replayer, except where the server would otherwise issue calls
+// of its own that use up recorded ones: Containers answers nothing until
+// Serving is called, so the server's startup scan does not take a recorded
+// List, and Lookup does not List at all.
+type Backend struct {
+	garden.Client
+	replayer *Replayer
+
+	mutex   sync.Mutex
+	serving bool
+}
+
+func NewBackend(events []Event) *Backend {
+	replayer := NewReplayer(events)
+	return &Backend{Client: client.New(replayer), replayer: replayer}
+}
+
+// Serving marks the server as started. The server calls Containers from its
+// own goroutines, so serving is only touched under mutex.
+func (b *Backend) Serving() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.serving = true
+}
+
+func (b *Backend) Start() error { return nil }
+
+func (b *Backend) Stop() {}
+
+func (b *Backend) GraceTime(garden.Container) time.Duration { return 0 }
+
+func (b *Backend) Containers(properties garden.Properties) ([]garden.Container, error) {
+	b.mutex.Lock()
+	serving := b.serving
+	b.mutex.Unlock()
+
+	if !serving {
+		return nil, nil
+	}
+	return b.Client.Containers(properties)
+}
+
+// Lookup returns a container for handle without asking the recording. The
+// server looks up the container for every call on one, and a client's Lookup
+// would List to do it; whether the handle exists shows in the recorded
+// answer to the call itself.
+func (b *Backend) Lookup(handle string) (garden.Container, error) {
+	return client.New(knownHandle{Connection: b.replayer, handle: handle}).Lookup(handle)
+}
+
+// knownHandle answers List with a single handle and passes everything else
+// to the recording.
+type knownHandle struct {
+	connection.Connection
+	handle string
+}
+
+func (c knownHandle) List(garden.Properties) ([]string, error) {
+	return []string{c.handle}, nil
+}
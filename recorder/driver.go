@@ -0,0 +1,322 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden/client/connection"
+)
+
+// Mismatch is a recorded call whose replayed result differs from the
+// recording.
+type Mismatch struct {
+	Event    Event
+	Response json.RawMessage
+	Error    string
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf(
+		"%s %s (seq %d)\n  recorded: %s (err: %q)\n  replayed: %s (err: %q)",
+		m.Event.Call, m.Event.Handle, m.Event.Seq, m.Event.Response, m.Event.Error, m.Response, m.Error,
+	)
+}
+
+// Drive re-issues the calls in a recording against conn one at a time, in the
+// order they started, and reports the ones that come back differently. Stdin
+// is fed from the recording; process output is not compared, only exit
+// statuses. Handles that the server generated are mapped to the ones it
+// generates this time.
+func Drive(conn connection.Connection, events []Event) []Mismatch {
+	d := &driver{conn: conn, handles: map[string]string{}, processes: map[uint64]garden.Process{}}
+
+	for _, event := range events {
+		if event.Call == chunkCall {
+			continue
+		}
+		d.issue(event, events)
+	}
+
+	d.waits.Wait()
+	return d.mismatches
+}
+
+type driver struct {
+	conn connection.Connection
+
+	handles   map[string]string
+	processes map[uint64]garden.Process
+	waits     sync.WaitGroup
+
+	mutex      sync.Mutex
+	mismatches []Mismatch
+}
+
+func (d *driver) handle(recorded string) string {
+	if handle, ok := d.handles[recorded]; ok {
+		return handle
+	}
+	return recorded
+}
+
+func (d *driver) compare(event Event, response interface{}, err error) {
+	replayed := Mismatch{Event: event}
+	if response != nil {
+		replayed.Response = encode(response)
+	}
+	if err != nil {
+		replayed.Error = err.Error()
+	}
+
+	if replayed.Error == event.Error && (response == nil || sameJSON(replayed.Response, event.Response)) {
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.mismatches = append(d.mismatches, replayed)
+}
+
+func (d *driver) unsupported(event Event, err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.mismatches = append(d.mismatches, Mismatch{Event: event, Error: "not replayed: " + err.Error()})
+}
+
+func (d *driver) issue(event Event, events []Event) {
+	handle := d.handle(event.Handle)
+	decode := func(v interface{}) bool {
+		if err := json.Unmarshal(event.Request, v); err != nil {
+			d.unsupported(event, err)
+			return false
+		}
+		return true
+	}
+
+	switch event.Call {
+	case "Ping":
+		d.compare(event, nil, d.conn.Ping())
+	case "Capacity":
+		capacity, err := d.conn.Capacity()
+		d.compare(event, capacity, err)
+	case "Create":
+		var spec garden.ContainerSpec
+		if !decode(&spec) {
+			return
+		}
+		created, err := d.conn.Create(spec)
+		if err == nil && spec.Handle == "" {
+			var recorded string
+			json.Unmarshal(event.Response, &recorded)
+			d.handles[recorded] = created
+			created = recorded
+		}
+		d.compare(event, created, err)
+	case "List":
+		var properties garden.Properties
+		if !decode(&properties) {
+			return
+		}
+		handles, err := d.conn.List(properties)
+		d.compare(event, handles, err)
+	case "Destroy":
+		d.compare(event, nil, d.conn.Destroy(handle))
+	case "Stop":
+		var request stopRequest
+		if !decode(&request) {
+			return
+		}
+		d.compare(event, nil, d.conn.Stop(handle, request.Kill))
+	case "Info":
+		info, err := d.conn.Info(handle)
+		d.compare(event, info, err)
+	case "BulkInfo", "BulkMetrics":
+		var handles []string
+		if !decode(&handles) {
+			return
+		}
+		for i := range handles {
+			handles[i] = d.handle(handles[i])
+		}
+		if event.Call == "BulkInfo" {
+			infos, err := d.conn.BulkInfo(handles)
+			d.compare(event, infos, err)
+		} else {
+			metrics, err := d.conn.BulkMetrics(handles)
+			d.compare(event, metrics, err)
+		}
+	case "StreamIn":
+		var request streamRequest
+		if !decode(&request) {
+			return
+		}
+		d.compare(event, nil, d.conn.StreamIn(handle, garden.StreamInSpec{
+			Path:    request.Path,
+			User:    request.User,
+			TarFile: bytes.NewReader(concat(events, event.Seq, "tar")),
+		}))
+	case "StreamOut":
+		var request streamRequest
+		if !decode(&request) {
+			return
+		}
+		stream, err := d.conn.StreamOut(handle, garden.StreamOutSpec{Path: request.Path, User: request.User})
+		if err == nil {
+			ioutil.ReadAll(stream)
+			stream.Close()
+		}
+		d.compare(event, nil, err)
+	case "LimitBandwidth":
+		var limits garden.BandwidthLimits
+		if !decode(&limits) {
+			return
+		}
+		result, err := d.conn.LimitBandwidth(handle, limits)
+		d.compare(event, result, err)
+	case "LimitCPU":
+		var limits garden.CPULimits
+		if !decode(&limits) {
+			return
+		}
+		result, err := d.conn.LimitCPU(handle, limits)
+		d.compare(event, result, err)
+	case "LimitDisk":
+		var limits garden.DiskLimits
+		if !decode(&limits) {
+			return
+		}
+		result, err := d.conn.LimitDisk(handle, limits)
+		d.compare(event, result, err)
+	case "LimitMemory":
+		var limits garden.MemoryLimits
+		if !decode(&limits) {
+			return
+		}
+		result, err := d.conn.LimitMemory(handle, limits)
+		d.compare(event, result, err)
+	case "CurrentBandwidthLimits":
+		result, err := d.conn.CurrentBandwidthLimits(handle)
+		d.compare(event, result, err)
+	case "CurrentCPULimits":
+		result, err := d.conn.CurrentCPULimits(handle)
+		d.compare(event, result, err)
+	case "CurrentDiskLimits":
+		result, err := d.conn.CurrentDiskLimits(handle)
+		d.compare(event, result, err)
+	case "CurrentMemoryLimits":
+		result, err := d.conn.CurrentMemoryLimits(handle)
+		d.compare(event, result, err)
+	case "Run", "Attach":
+		processIO := garden.ProcessIO{
+			Stdin:  bytes.NewReader(concat(events, event.Seq, "stdin")),
+			Stdout: ioutil.Discard,
+			Stderr: ioutil.Discard,
+		}
+
+		var process garden.Process
+		var err error
+		if event.Call == "Run" {
+			var spec garden.ProcessSpec
+			if !decode(&spec) {
+				return
+			}
+			process, err = d.conn.Run(handle, spec, processIO)
+		} else {
+			var request attachRequest
+			if !decode(&request) {
+				return
+			}
+			process, err = d.conn.Attach(handle, request.ProcessID, processIO)
+		}
+
+		// process IDs differ from run to run, so only errors are compared
+		if err == nil {
+			d.processes[event.Seq] = process
+		}
+		d.compare(event, nil, err)
+	case "Wait":
+		process, ok := d.processes[event.Ref]
+		if !ok {
+			return
+		}
+		d.waits.Add(1)
+		go func() {
+			defer d.waits.Done()
+			status, err := process.Wait()
+			d.compare(event, status, err)
+		}()
+	case "Signal":
+		var signal garden.Signal
+		process, ok := d.processes[event.Ref]
+		if !ok || !decode(&signal) {
+			return
+		}
+		d.compare(event, nil, process.Signal(signal))
+	case "SetTTY":
+		var spec garden.TTYSpec
+		process, ok := d.processes[event.Ref]
+		if !ok || !decode(&spec) {
+			return
+		}
+		d.compare(event, nil, process.SetTTY(spec))
+	case "NetIn":
+		var request netInRequest
+		if !decode(&request) {
+			return
+		}
+		hostPort, containerPort, err := d.conn.NetIn(handle, request.HostPort, request.ContainerPort)
+		d.compare(event, netInResponse{HostPort: hostPort, ContainerPort: containerPort}, err)
+	case "NetOut":
+		var rule garden.NetOutRule
+		if !decode(&rule) {
+			return
+		}
+		d.compare(event, nil, d.conn.NetOut(handle, rule))
+	case "GetProperty":
+		var request propertyRequest
+		if !decode(&request) {
+			return
+		}
+		value, err := d.conn.GetProperty(handle, request.Name)
+		d.compare(event, value, err)
+	case "SetProperty":
+		var request propertyRequest
+		if !decode(&request) {
+			return
+		}
+		d.compare(event, nil, d.conn.SetProperty(handle, request.Name, request.Value))
+	case "RemoveProperty":
+		var request propertyRequest
+		if !decode(&request) {
+			return
+		}
+		d.compare(event, nil, d.conn.RemoveProperty(handle, request.Name))
+	case "Metrics":
+		metrics, err := d.conn.Metrics(handle)
+		d.compare(event, metrics, err)
+	default:
+		d.unsupported(event, fmt.Errorf("unknown call %s", event.Call))
+	}
+}
+
+func concat(events []Event, ref uint64, stream string) []byte {
+	var data []byte
+	for _, event := range events {
+		if event.Call == chunkCall && event.Ref == ref && event.Stream == stream {
+			data = append(data, event.Chunk...)
+		}
+	}
+	return data
+}
+
+func sameJSON(a, b json.RawMessage) bool {
+	var compactA, compactB bytes.Buffer
+	if json.Compact(&compactA, a) != nil || json.Compact(&compactB, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(compactA.Bytes(), compactB.Bytes())
+}
@@ -0,0 +1,450 @@
+// Package recorder captures the traffic between a Garden client and server:
+// every call with its request, response and error, and every chunk of process
+// and file streams, each with a timestamp. A recording can be written out when
+// a spec fails and replayed later, either by serving it in place of a server
+// (Replayer, or Backend behind a real Garden server) or by re-issuing its calls
+// against one (Drive).
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden/client/connection"
+)
+
+// Event is one recorded call or stream chunk. Chunks, and the Wait, Signal
+// and SetTTY calls on a process, refer to the Run, Attach, StreamIn or
+// StreamOut call they belong to by its Seq. A chunk recorded past the
+// Recorder's ChunkBudget has no Chunk, only the length of what was Omitted.
+type Event struct {
+	Seq      uint64          `json:"seq"`
+	Ref      uint64          `json:"ref,omitempty"`
+	Time     time.Time       `json:"time"`
+	Duration time.Duration   `json:"duration,omitempty"`
+	Call     string          `json:"call"`
+	Handle   string          `json:"handle,omitempty"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Stream   string          `json:"stream,omitempty"`
+	Chunk    []byte          `json:"chunk,omitempty"`
+	Omitted  int             `json:"omitted,omitempty"`
+}
+
+const chunkCall = "Chunk"
+
+type netInRequest struct {
+	HostPort      uint32 `json:"host_port"`
+	ContainerPort uint32 `json:"container_port"`
+}
+
+type netInResponse netInRequest
+
+type propertyRequest struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+type stopRequest struct {
+	Kill bool `json:"kill"`
+}
+
+type attachRequest struct {
+	ProcessID uint32 `json:"process_id"`
+}
+
+type processResponse struct {
+	ProcessID uint32 `json:"process_id"`
+}
+
+type streamRequest struct {
+	Path string `json:"path"`
+	User string `json:"user"`
+}
+
+// DefaultChunkBudget bounds the stream payload a Recorder keeps, so that soak
+// and benchmark runs, which stream far more than a failing spec needs, do not
+// grow without limit.
+const DefaultChunkBudget = 16 << 20
+
+// Recorder is a connection.Connection that records everything passing
+// through the connection it wraps. It keeps the bytes of stream chunks until
+// they add up to ChunkBudget, and after that only their lengths.
+type Recorder struct {
+	connection.Connection
+	ChunkBudget int
+
+	mutex      sync.Mutex
+	seq        uint64
+	events     []Event
+	chunkBytes int
+}
+
+func New(inner connection.Connection) *Recorder {
+	return &Recorder{Connection: inner, ChunkBudget: DefaultChunkBudget}
+}
+
+// Events returns the recording so far, ordered by when each event started.
+func (r *Recorder) Events() []Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	events := append([]Event{}, r.events...)
+	sort.Sort(bySeq(events))
+	return events
+}
+
+// Reset discards the recording so far.
+func (r *Recorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = nil
+	r.chunkBytes = 0
+}
+
+// Save writes the recording to path as one JSON event per line.
+func (r *Recorder) Save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return Write(file, r.Events())
+}
+
+func Write(w io.Writer, events []Event) error {
+	encoder := json.NewEncoder(w)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load reads a recording written by Save.
+func Load(r io.Reader) ([]Event, error) {
+	var events []Event
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var event Event
+		err := decoder.Decode(&event)
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+}
+
+func (r *Recorder) nextSeq() uint64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.seq++
+	return r.seq
+}
+
+func (r *Recorder) append(event Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, event)
+}
+
+// record performs a call through f and records it. ref is zero unless the
+// call belongs to an earlier one.
+func (r *Recorder) record(ref uint64, call, handle string, request interface{}, f func() (interface{}, error)) error {
+	seq, start := r.nextSeq(), time.Now()
+	response, err := f()
+	r.finish(seq, ref, start, call, handle, request, response, err)
+	return err
+}
+
+// finish records a call that has returned. Calls that stream take their seq
+// before they start so that their chunks can refer to it.
+func (r *Recorder) finish(seq, ref uint64, start time.Time, call, handle string, request, response interface{}, err error) {
+	event := Event{
+		Seq:      seq,
+		Ref:      ref,
+		Time:     start,
+		Duration: time.Since(start),
+		Call:     call,
+		Handle:   handle,
+	}
+	if request != nil {
+		event.Request = encode(request)
+	}
+	if response != nil {
+		event.Response = encode(response)
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	r.append(event)
+}
+
+func (r *Recorder) chunk(ref uint64, handle, stream string, data []byte) {
+	event := Event{
+		Seq:    r.nextSeq(),
+		Ref:    ref,
+		Time:   time.Now(),
+		Call:   chunkCall,
+		Handle: handle,
+		Stream: stream,
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.chunkBytes+len(data) <= r.ChunkBudget {
+		event.Chunk = append([]byte{}, data...)
+		r.chunkBytes += len(data)
+	} else {
+		event.Omitted = len(data)
+	}
+	r.events = append(r.events, event)
+}
+
+func encode(v interface{}) json.RawMessage {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		encoded, _ = json.Marshal(map[string]string{"unrecordable": err.Error()})
+	}
+	return encoded
+}
+
+type bySeq []Event
+
+func (events bySeq) Len() int           { return len(events) }
+func (events bySeq) Less(i, j int) bool { return events[i].Seq < events[j].Seq }
+func (events bySeq) Swap(i, j int)      { events[i], events[j] = events[j], events[i] }
+
+func (r *Recorder) Ping() error {
+	return r.record(0, "Ping", "", nil, func() (interface{}, error) {
+		return nil, r.Connection.Ping()
+	})
+}
+
+func (r *Recorder) Capacity() (capacity garden.Capacity, err error) {
+	err = r.record(0, "Capacity", "", nil, func() (interface{}, error) {
+		capacity, err = r.Connection.Capacity()
+		return capacity, err
+	})
+	return
+}
+
+func (r *Recorder) Create(spec garden.ContainerSpec) (handle string, err error) {
+	err = r.record(0, "Create", spec.Handle, spec, func() (interface{}, error) {
+		handle, err = r.Connection.Create(spec)
+		return handle, err
+	})
+	return
+}
+
+func (r *Recorder) List(properties garden.Properties) (handles []string, err error) {
+	err = r.record(0, "List", "", properties, func() (interface{}, error) {
+		handles, err = r.Connection.List(properties)
+		return handles, err
+	})
+	return
+}
+
+func (r *Recorder) Destroy(handle string) error {
+	return r.record(0, "Destroy", handle, nil, func() (interface{}, error) {
+		return nil, r.Connection.Destroy(handle)
+	})
+}
+
+func (r *Recorder) Stop(handle string, kill bool) error {
+	return r.record(0, "Stop", handle, stopRequest{Kill: kill}, func() (interface{}, error) {
+		return nil, r.Connection.Stop(handle, kill)
+	})
+}
+
+func (r *Recorder) Info(handle string) (info garden.ContainerInfo, err error) {
+	err = r.record(0, "Info", handle, nil, func() (interface{}, error) {
+		info, err = r.Connection.Info(handle)
+		return info, err
+	})
+	return
+}
+
+func (r *Recorder) BulkInfo(handles []string) (infos map[string]garden.ContainerInfoEntry, err error) {
+	err = r.record(0, "BulkInfo", "", handles, func() (interface{}, error) {
+		infos, err = r.Connection.BulkInfo(handles)
+		return infos, err
+	})
+	return
+}
+
+func (r *Recorder) BulkMetrics(handles []string) (metrics map[string]garden.ContainerMetricsEntry, err error) {
+	err = r.record(0, "BulkMetrics", "", handles, func() (interface{}, error) {
+		metrics, err = r.Connection.BulkMetrics(handles)
+		return metrics, err
+	})
+	return
+}
+
+func (r *Recorder) StreamIn(handle string, spec garden.StreamInSpec) error {
+	ref, start := r.nextSeq(), time.Now()
+	if spec.TarFile != nil {
+		spec.TarFile = &chunkReader{Reader: spec.TarFile, recorder: r, ref: ref, handle: handle, stream: "tar"}
+	}
+
+	err := r.Connection.StreamIn(handle, spec)
+	r.finish(ref, 0, start, "StreamIn", handle, streamRequest{Path: spec.Path, User: spec.User}, nil, err)
+	return err
+}
+
+func (r *Recorder) StreamOut(handle string, spec garden.StreamOutSpec) (io.ReadCloser, error) {
+	ref, start := r.nextSeq(), time.Now()
+
+	stream, err := r.Connection.StreamOut(handle, spec)
+	r.finish(ref, 0, start, "StreamOut", handle, streamRequest{Path: spec.Path, User: spec.User}, nil, err)
+	if err != nil {
+		return nil, err
+	}
+	return &chunkReadCloser{
+		chunkReader: chunkReader{Reader: stream, recorder: r, ref: ref, handle: handle, stream: "tar"},
+		closer:      stream,
+	}, nil
+}
+
+func (r *Recorder) LimitBandwidth(handle string, limits garden.BandwidthLimits) (result garden.BandwidthLimits, err error) {
+	err = r.record(0, "LimitBandwidth", handle, limits, func() (interface{}, error) {
+		result, err = r.Connection.LimitBandwidth(handle, limits)
+		return result, err
+	})
+	return
+}
+
+func (r *Recorder) LimitCPU(handle string, limits garden.CPULimits) (result garden.CPULimits, err error) {
+	err = r.record(0, "LimitCPU", handle, limits, func() (interface{}, error) {
+		result, err = r.Connection.LimitCPU(handle, limits)
+		return result, err
+	})
+	return
+}
+
+func (r *Recorder) LimitDisk(handle string, limits garden.DiskLimits) (result garden.DiskLimits, err error) {
+	err = r.record(0, "LimitDisk", handle, limits, func() (interface{}, error) {
+		result, err = r.Connection.LimitDisk(handle, limits)
+		return result, err
+	})
+	return
+}
+
+func (r *Recorder) LimitMemory(handle string, limits garden.MemoryLimits) (result garden.MemoryLimits, err error) {
+	err = r.record(0, "LimitMemory", handle, limits, func() (interface{}, error) {
+		result, err = r.Connection.LimitMemory(handle, limits)
+		return result, err
+	})
+	return
+}
+
+func (r *Recorder) CurrentBandwidthLimits(handle string) (result garden.BandwidthLimits, err error) {
+	err = r.record(0, "CurrentBandwidthLimits", handle, nil, func() (interface{}, error) {
+		result, err = r.Connection.CurrentBandwidthLimits(handle)
+		return result, err
+	})
+	return
+}
+
+func (r *Recorder) CurrentCPULimits(handle string) (result garden.CPULimits, err error) {
+	err = r.record(0, "CurrentCPULimits", handle, nil, func() (interface{}, error) {
+		result, err = r.Connection.CurrentCPULimits(handle)
+		return result, err
+	})
+	return
+}
+
+func (r *Recorder) CurrentDiskLimits(handle string) (result garden.DiskLimits, err error) {
+	err = r.record(0, "CurrentDiskLimits", handle, nil, func() (interface{}, error) {
+		result, err = r.Connection.CurrentDiskLimits(handle)
+		return result, err
+	})
+	return
+}
+
+func (r *Recorder) CurrentMemoryLimits(handle string) (result garden.MemoryLimits, err error) {
+	err = r.record(0, "CurrentMemoryLimits", handle, nil, func() (interface{}, error) {
+		result, err = r.Connection.CurrentMemoryLimits(handle)
+		return result, err
+	})
+	return
+}
+
+func (r *Recorder) Run(handle string, spec garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
+	ref, start := r.nextSeq(), time.Now()
+
+	process, err := r.Connection.Run(handle, spec, r.recordIO(ref, handle, processIO))
+	if err != nil {
+		r.finish(ref, 0, start, "Run", handle, spec, nil, err)
+		return nil, err
+	}
+	r.finish(ref, 0, start, "Run", handle, spec, processResponse{ProcessID: process.ID()}, nil)
+	return &recordedProcess{Process: process, recorder: r, ref: ref, handle: handle}, nil
+}
+
+func (r *Recorder) Attach(handle string, processID uint32, processIO garden.ProcessIO) (garden.Process, error) {
+	ref, start := r.nextSeq(), time.Now()
+
+	process, err := r.Connection.Attach(handle, processID, r.recordIO(ref, handle, processIO))
+	if err != nil {
+		r.finish(ref, 0, start, "Attach", handle, attachRequest{ProcessID: processID}, nil, err)
+		return nil, err
+	}
+	r.finish(ref, 0, start, "Attach", handle, attachRequest{ProcessID: processID}, processResponse{ProcessID: process.ID()}, nil)
+	return &recordedProcess{Process: process, recorder: r, ref: ref, handle: handle}, nil
+}
+
+func (r *Recorder) NetIn(handle string, hostPort, containerPort uint32) (resultHostPort, resultContainerPort uint32, err error) {
+	request := netInRequest{HostPort: hostPort, ContainerPort: containerPort}
+	err = r.record(0, "NetIn", handle, request, func() (interface{}, error) {
+		resultHostPort, resultContainerPort, err = r.Connection.NetIn(handle, hostPort, containerPort)
+		return netInResponse{HostPort: resultHostPort, ContainerPort: resultContainerPort}, err
+	})
+	return
+}
+
+func (r *Recorder) NetOut(handle string, rule garden.NetOutRule) error {
+	return r.record(0, "NetOut", handle, rule, func() (interface{}, error) {
+		return nil, r.Connection.NetOut(handle, rule)
+	})
+}
+
+func (r *Recorder) GetProperty(handle string, name string) (value string, err error) {
+	err = r.record(0, "GetProperty", handle, propertyRequest{Name: name}, func() (interface{}, error) {
+		value, err = r.Connection.GetProperty(handle, name)
+		return value, err
+	})
+	return
+}
+
+func (r *Recorder) SetProperty(handle string, name string, value string) error {
+	return r.record(0, "SetProperty", handle, propertyRequest{Name: name, Value: value}, func() (interface{}, error) {
+		return nil, r.Connection.SetProperty(handle, name, value)
+	})
+}
+
+func (r *Recorder) RemoveProperty(handle string, name string) error {
+	return r.record(0, "RemoveProperty", handle, propertyRequest{Name: name}, func() (interface{}, error) {
+		return nil, r.Connection.RemoveProperty(handle, name)
+	})
+}
+
+func (r *Recorder) Metrics(handle string) (metrics garden.Metrics, err error) {
+	err = r.record(0, "Metrics", handle, nil, func() (interface{}, error) {
+		metrics, err = r.Connection.Metrics(handle)
+		return metrics, err
+	})
+	return
+}
@@ -0,0 +1,151 @@
+package recorder_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-acceptance/recorder"
+	"github.com/cloudfoundry-incubator/garden/client/connection"
+	"github.com/cloudfoundry-incubator/garden/server"
+	"github.com/pivotal-golang/lager"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("recorder", func() {
+	var recording []recorder.Event
+
+	BeforeEach(func() {
+		recording = []recorder.Event{
+			{Seq: 1, Call: "Create", Request: json.RawMessage(`{}`), Response: json.RawMessage(`"handle"`)},
+			{Seq: 2, Call: "SetProperty", Handle: "handle", Request: json.RawMessage(`{"name":"k","value":"v"}`), Error: "boom"},
+			{Seq: 3, Call: "Run", Handle: "handle", Request: json.RawMessage(`{"Path":"echo"}`), Response: json.RawMessage(`{"process_id":42}`)},
+			{Seq: 4, Ref: 3, Call: "Chunk", Handle: "handle", Stream: "stdout", Chunk: []byte("hello ")},
+			{Seq: 5, Ref: 3, Call: "Chunk", Handle: "handle", Stream: "stdout", Chunk: []byte("world")},
+			{Seq: 6, Ref: 3, Call: "Wait", Handle: "handle", Response: json.RawMessage(`3`)},
+		}
+	})
+
+	It("records calls, their results and their streams", func() {
+		r := recorder.New(recorder.NewReplayer(recording))
+
+		handle, err := r.Create(garden.ContainerSpec{})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(handle).Should(Equal("handle"))
+
+		Ω(r.SetProperty("handle", "k", "v")).Should(MatchError("boom"))
+
+		stdout := &bytes.Buffer{}
+		process, err := r.Run("handle", garden.ProcessSpec{Path: "echo"}, garden.ProcessIO{Stdout: stdout})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(process.ID()).Should(Equal(uint32(42)))
+		Ω(process.Wait()).Should(Equal(3))
+		Ω(stdout.String()).Should(Equal("hello world"))
+
+		var calls []string
+		events := r.Events()
+		for _, event := range events {
+			calls = append(calls, event.Call+":"+event.Stream)
+		}
+		// Wait starts while output may still be arriving
+		Ω(calls[:3]).Should(Equal([]string{"Create:", "SetProperty:", "Run:"}))
+		Ω(calls[3:]).Should(ConsistOf("Chunk:stdout", "Chunk:stdout", "Wait:"))
+		Ω(events[1].Error).Should(Equal("boom"))
+		Ω(string(events[2].Response)).Should(Equal(`{"process_id":42}`))
+		for _, event := range events[3:] {
+			Ω(event.Ref).Should(Equal(events[2].Seq))
+		}
+	})
+
+	It("keeps chunk bytes only up to its budget", func() {
+		r := recorder.New(recorder.NewReplayer(recording))
+		r.ChunkBudget = 8
+
+		process, err := r.Run("handle", garden.ProcessSpec{Path: "echo"}, garden.ProcessIO{Stdout: &bytes.Buffer{}})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(process.Wait()).Should(Equal(3))
+
+		var kept, omitted []string
+		for _, event := range r.Events() {
+			if event.Call == "Chunk" {
+				kept = append(kept, string(event.Chunk))
+				omitted = append(omitted, fmt.Sprint(event.Omitted))
+			}
+		}
+		Ω(kept).Should(Equal([]string{"hello ", ""}))
+		Ω(omitted).Should(Equal([]string{"0", "5"}))
+
+		r.Reset()
+		Ω(r.Events()).Should(BeEmpty())
+	})
+
+	It("round-trips through Write and Load", func() {
+		written := &bytes.Buffer{}
+		Ω(recorder.Write(written, recording)).Should(Succeed())
+
+		loaded, err := recorder.Load(bytes.NewReader(written.Bytes()))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(loaded).Should(HaveLen(len(recording)))
+
+		rewritten := &bytes.Buffer{}
+		Ω(recorder.Write(rewritten, loaded)).Should(Succeed())
+		Ω(rewritten.String()).Should(Equal(written.String()))
+	})
+
+	Describe("Replayer", func() {
+		It("fails calls that were not recorded", func() {
+			replayer := recorder.NewReplayer(recording)
+			Ω(replayer.Destroy("other")).Should(MatchError(`no recorded Destroy for handle "other"`))
+		})
+
+		It("replays each recorded call once", func() {
+			replayer := recorder.NewReplayer(recording)
+			Ω(replayer.Create(garden.ContainerSpec{})).Should(Equal("handle"))
+			_, err := replayer.Create(garden.ContainerSpec{})
+			Ω(err).Should(HaveOccurred())
+			Ω(replayer.Unused()).Should(HaveLen(3))
+		})
+	})
+
+	Describe("Backend", func() {
+		It("replays through a Garden server without using up recorded Lists", func() {
+			served := append([]recorder.Event{
+				{Seq: 7, Call: "List", Request: json.RawMessage(`{}`), Response: json.RawMessage(`["handle"]`)},
+				{Seq: 8, Call: "Info", Handle: "handle", Response: json.RawMessage(`{"ContainerIP":"10.0.0.2"}`)},
+			}, recording...)
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Ω(err).ShouldNot(HaveOccurred())
+			address := listener.Addr().String()
+			listener.Close()
+
+			backend := recorder.NewBackend(served)
+			gardenServer := server.New("tcp", address, 0, backend, lager.NewLogger("backend"))
+			Ω(gardenServer.Start()).Should(Succeed())
+			defer gardenServer.Stop()
+			backend.Serving()
+
+			Ω(recorder.Drive(connection.New("tcp", address), served)).Should(BeEmpty())
+		})
+	})
+
+	Describe("Drive", func() {
+		It("reports nothing when the replay matches", func() {
+			Ω(recorder.Drive(recorder.NewReplayer(recording), recording)).Should(BeEmpty())
+		})
+
+		It("reports calls that come back differently", func() {
+			changed := append([]recorder.Event{}, recording...)
+			changed[5].Response = json.RawMessage(`0`)
+
+			mismatches := recorder.Drive(recorder.NewReplayer(changed), recording)
+			Ω(mismatches).Should(HaveLen(1))
+			Ω(mismatches[0].Event.Call).Should(Equal("Wait"))
+			Ω(string(mismatches[0].Response)).Should(Equal("0"))
+		})
+	})
+})
@@ -0,0 +1,323 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden/client/connection"
+)
+
+// Replayer is a connection.Connection that answers from a recording instead
+// of a server. Each call is matched to the first unused recorded call of the
+// same kind on the same handle, preferring one with an identical request, so
+// concurrent specs replay even if their calls interleave differently. Errors
+// come back with the recorded message but lose their type.
+type Replayer struct {
+	events []Event
+	used   map[uint64]bool
+	mutex  sync.Mutex
+}
+
+var _ connection.Connection = &Replayer{}
+
+func NewReplayer(events []Event) *Replayer {
+	return &Replayer{
+		events: events,
+		used:   map[uint64]bool{},
+	}
+}
+
+// Unused returns the recorded calls that have not been replayed.
+func (r *Replayer) Unused() []Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var unused []Event
+	for _, event := range r.events {
+		if event.Call != chunkCall && !r.used[event.Seq] {
+			unused = append(unused, event)
+		}
+	}
+	return unused
+}
+
+func (r *Replayer) take(ref uint64, call, handle string, request interface{}) (Event, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var encoded json.RawMessage
+	if request != nil {
+		encoded = encode(request)
+	}
+
+	candidate := -1
+	for i, event := range r.events {
+		if r.used[event.Seq] || event.Call != call || event.Handle != handle || (ref != 0 && event.Ref != ref) {
+			continue
+		}
+		if bytes.Equal(event.Request, encoded) {
+			candidate = i
+			break
+		}
+		if candidate < 0 {
+			candidate = i
+		}
+	}
+
+	if candidate < 0 {
+		return Event{}, fmt.Errorf("no recorded %s for handle %q", call, handle)
+	}
+
+	event := r.events[candidate]
+	r.used[event.Seq] = true
+	return event, nil
+}
+
+func (r *Replayer) chunks(ref uint64, stream string) [][]byte {
+	var chunks [][]byte
+	for _, event := range r.events {
+		if event.Call == chunkCall && event.Ref == ref && event.Stream == stream {
+			chunks = append(chunks, event.Chunk)
+		}
+	}
+	return chunks
+}
+
+// replay answers call from the recording, decoding its response into
+// response if given.
+func (r *Replayer) replay(ref uint64, call, handle string, request, response interface{}) (Event, error) {
+	event, err := r.take(ref, call, handle, request)
+	if err != nil {
+		return event, err
+	}
+
+	if response != nil && len(event.Response) > 0 {
+		if err := json.Unmarshal(event.Response, response); err != nil {
+			return event, fmt.Errorf("recorded %s response: %s", call, err)
+		}
+	}
+
+	if event.Error != "" {
+		return event, errors.New(event.Error)
+	}
+	return event, nil
+}
+
+func (r *Replayer) Ping() error {
+	_, err := r.replay(0, "Ping", "", nil, nil)
+	return err
+}
+
+func (r *Replayer) Capacity() (capacity garden.Capacity, err error) {
+	_, err = r.replay(0, "Capacity", "", nil, &capacity)
+	return
+}
+
+func (r *Replayer) Create(spec garden.ContainerSpec) (handle string, err error) {
+	_, err = r.replay(0, "Create", spec.Handle, spec, &handle)
+	return
+}
+
+func (r *Replayer) List(properties garden.Properties) (handles []string, err error) {
+	_, err = r.replay(0, "List", "", properties, &handles)
+	return
+}
+
+func (r *Replayer) Destroy(handle string) error {
+	_, err := r.replay(0, "Destroy", handle, nil, nil)
+	return err
+}
+
+func (r *Replayer) Stop(handle string, kill bool) error {
+	_, err := r.replay(0, "Stop", handle, stopRequest{Kill: kill}, nil)
+	return err
+}
+
+func (r *Replayer) Info(handle string) (info garden.ContainerInfo, err error) {
+	_, err = r.replay(0, "Info", handle, nil, &info)
+	return
+}
+
+func (r *Replayer) BulkInfo(handles []string) (infos map[string]garden.ContainerInfoEntry, err error) {
+	_, err = r.replay(0, "BulkInfo", "", handles, &infos)
+	return
+}
+
+func (r *Replayer) BulkMetrics(handles []string) (metrics map[string]garden.ContainerMetricsEntry, err error) {
+	_, err = r.replay(0, "BulkMetrics", "", handles, &metrics)
+	return
+}
+
+func (r *Replayer) StreamIn(handle string, spec garden.StreamInSpec) error {
+	if spec.TarFile != nil {
+		io.Copy(ioutil.Discard, spec.TarFile)
+	}
+	_, err := r.replay(0, "StreamIn", handle, streamRequest{Path: spec.Path, User: spec.User}, nil)
+	return err
+}
+
+func (r *Replayer) StreamOut(handle string, spec garden.StreamOutSpec) (io.ReadCloser, error) {
+	event, err := r.replay(0, "StreamOut", handle, streamRequest{Path: spec.Path, User: spec.User}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(io.MultiReader(readers(r.chunks(event.Seq, "tar"))...)), nil
+}
+
+func (r *Replayer) LimitBandwidth(handle string, limits garden.BandwidthLimits) (result garden.BandwidthLimits, err error) {
+	_, err = r.replay(0, "LimitBandwidth", handle, limits, &result)
+	return
+}
+
+func (r *Replayer) LimitCPU(handle string, limits garden.CPULimits) (result garden.CPULimits, err error) {
+	_, err = r.replay(0, "LimitCPU", handle, limits, &result)
+	return
+}
+
+func (r *Replayer) LimitDisk(handle string, limits garden.DiskLimits) (result garden.DiskLimits, err error) {
+	_, err = r.replay(0, "LimitDisk", handle, limits, &result)
+	return
+}
+
+func (r *Replayer) LimitMemory(handle string, limits garden.MemoryLimits) (result garden.MemoryLimits, err error) {
+	_, err = r.replay(0, "LimitMemory", handle, limits, &result)
+	return
+}
+
+func (r *Replayer) CurrentBandwidthLimits(handle string) (result garden.BandwidthLimits, err error) {
+	_, err = r.replay(0, "CurrentBandwidthLimits", handle, nil, &result)
+	return
+}
+
+func (r *Replayer) CurrentCPULimits(handle string) (result garden.CPULimits, err error) {
+	_, err = r.replay(0, "CurrentCPULimits", handle, nil, &result)
+	return
+}
+
+func (r *Replayer) CurrentDiskLimits(handle string) (result garden.DiskLimits, err error) {
+	_, err = r.replay(0, "CurrentDiskLimits", handle, nil, &result)
+	return
+}
+
+func (r *Replayer) CurrentMemoryLimits(handle string) (result garden.MemoryLimits, err error) {
+	_, err = r.replay(0, "CurrentMemoryLimits", handle, nil, &result)
+	return
+}
+
+func (r *Replayer) Run(handle string, spec garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
+	var response processResponse
+	event, err := r.replay(0, "Run", handle, spec, &response)
+	if err != nil {
+		return nil, err
+	}
+	return r.startProcess(event, handle, response.ProcessID, processIO), nil
+}
+
+func (r *Replayer) Attach(handle string, processID uint32, processIO garden.ProcessIO) (garden.Process, error) {
+	var response processResponse
+	event, err := r.replay(0, "Attach", handle, attachRequest{ProcessID: processID}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return r.startProcess(event, handle, response.ProcessID, processIO), nil
+}
+
+func (r *Replayer) NetIn(handle string, hostPort, containerPort uint32) (uint32, uint32, error) {
+	var response netInResponse
+	_, err := r.replay(0, "NetIn", handle, netInRequest{HostPort: hostPort, ContainerPort: containerPort}, &response)
+	return response.HostPort, response.ContainerPort, err
+}
+
+func (r *Replayer) NetOut(handle string, rule garden.NetOutRule) error {
+	_, err := r.replay(0, "NetOut", handle, rule, nil)
+	return err
+}
+
+func (r *Replayer) GetProperty(handle string, name string) (value string, err error) {
+	_, err = r.replay(0, "GetProperty", handle, propertyRequest{Name: name}, &value)
+	return
+}
+
+func (r *Replayer) SetProperty(handle string, name string, value string) error {
+	_, err := r.replay(0, "SetProperty", handle, propertyRequest{Name: name, Value: value}, nil)
+	return err
+}
+
+func (r *Replayer) RemoveProperty(handle string, name string) error {
+	_, err := r.replay(0, "RemoveProperty", handle, propertyRequest{Name: name}, nil)
+	return err
+}
+
+func (r *Replayer) Metrics(handle string) (metrics garden.Metrics, err error) {
+	_, err = r.replay(0, "Metrics", handle, nil, &metrics)
+	return
+}
+
+// startProcess writes the recorded output of the Run or Attach call event
+// to processIO. Stdin is read and discarded.
+func (r *Replayer) startProcess(event Event, handle string, id uint32, processIO garden.ProcessIO) garden.Process {
+	process := &replayedProcess{replayer: r, ref: event.Seq, handle: handle, id: id, done: make(chan struct{})}
+
+	if processIO.Stdin != nil {
+		go io.Copy(ioutil.Discard, processIO.Stdin)
+	}
+
+	go func() {
+		defer close(process.done)
+		for _, stream := range []struct {
+			name string
+			w    io.Writer
+		}{{"stdout", processIO.Stdout}, {"stderr", processIO.Stderr}} {
+			if stream.w == nil {
+				continue
+			}
+			for _, chunk := range r.chunks(event.Seq, stream.name) {
+				stream.w.Write(chunk)
+			}
+		}
+	}()
+
+	return process
+}
+
+type replayedProcess struct {
+	replayer *Replayer
+	ref      uint64
+	handle   string
+	id       uint32
+	done     chan struct{}
+}
+
+func (p *replayedProcess) ID() uint32 {
+	return p.id
+}
+
+func (p *replayedProcess) Wait() (status int, err error) {
+	<-p.done
+	_, err = p.replayer.replay(p.ref, "Wait", p.handle, nil, &status)
+	return
+}
+
+func (p *replayedProcess) Signal(signal garden.Signal) error {
+	_, err := p.replayer.replay(p.ref, "Signal", p.handle, signal, nil)
+	return err
+}
+
+func (p *replayedProcess) SetTTY(spec garden.TTYSpec) error {
+	_, err := p.replayer.replay(p.ref, "SetTTY", p.handle, spec, nil)
+	return err
+}
+
+func readers(chunks [][]byte) []io.Reader {
+	var readers []io.Reader
+	for _, chunk := range chunks {
+		readers = append(readers, bytes.NewReader(chunk))
+	}
+	return readers
+}
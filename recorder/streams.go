@@ -0,0 +1,87 @@
+package recorder
+
+import (
+	"io"
+
+	"github.com/cloudfoundry-incubator/garden"
+)
+
+// recordIO wraps each of a process's streams so that what passes through is
+// recorded against the Run or Attach call ref.
+func (r *Recorder) recordIO(ref uint64, handle string, processIO garden.ProcessIO) garden.ProcessIO {
+	if processIO.Stdin != nil {
+		processIO.Stdin = &chunkReader{Reader: processIO.Stdin, recorder: r, ref: ref, handle: handle, stream: "stdin"}
+	}
+	if processIO.Stdout != nil {
+		processIO.Stdout = &chunkWriter{Writer: processIO.Stdout, recorder: r, ref: ref, handle: handle, stream: "stdout"}
+	}
+	if processIO.Stderr != nil {
+		processIO.Stderr = &chunkWriter{Writer: processIO.Stderr, recorder: r, ref: ref, handle: handle, stream: "stderr"}
+	}
+	return processIO
+}
+
+type chunkReader struct {
+	io.Reader
+	recorder *Recorder
+	ref      uint64
+	handle   string
+	stream   string
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.recorder.chunk(r.ref, r.handle, r.stream, p[:n])
+	}
+	return n, err
+}
+
+type chunkReadCloser struct {
+	chunkReader
+	closer io.Closer
+}
+
+func (r *chunkReadCloser) Close() error {
+	return r.closer.Close()
+}
+
+type chunkWriter struct {
+	io.Writer
+	recorder *Recorder
+	ref      uint64
+	handle   string
+	stream   string
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.recorder.chunk(w.ref, w.handle, w.stream, p)
+	return w.Writer.Write(p)
+}
+
+type recordedProcess struct {
+	garden.Process
+	recorder *Recorder
+	ref      uint64
+	handle   string
+}
+
+func (p *recordedProcess) Wait() (status int, err error) {
+	err = p.recorder.record(p.ref, "Wait", p.handle, nil, func() (interface{}, error) {
+		status, err = p.Process.Wait()
+		return status, err
+	})
+	return
+}
+
+func (p *recordedProcess) Signal(signal garden.Signal) error {
+	return p.recorder.record(p.ref, "Signal", p.handle, signal, func() (interface{}, error) {
+		return nil, p.Process.Signal(signal)
+	})
+}
+
+func (p *recordedProcess) SetTTY(spec garden.TTYSpec) error {
+	return p.recorder.record(p.ref, "SetTTY", p.handle, spec, func() (interface{}, error) {
+		return nil, p.Process.SetTTY(spec)
+	})
+}
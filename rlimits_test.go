@@ -0,0 +1,161 @@
+package garden_acceptance_test
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+// rlimit is one row of /proc/<pid>/limits. Values are as the kernel prints
+// them: a number in the row's units, or "unlimited".
+type rlimit struct {
+	Soft, Hard string
+}
+
+var procLimitsRow = regexp.MustCompile(`^(Max [a-z ]+?)\s{2,}(\S+)\s+(\S+)`)
+
+// parseProcLimits parses /proc/<pid>/limits, keyed by row name such as
+// "Max open files".
+func parseProcLimits(contents string) (map[string]rlimit, error) {
+	limits := map[string]rlimit{}
+	for _, line := range strings.Split(contents, "\n") {
+		if line == "" || strings.HasPrefix(line, "Limit ") {
+			continue
+		}
+		match := procLimitsRow.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("malformed limits line %q", line)
+		}
+		limits[match[1]] = rlimit{Soft: match[2], Hard: match[3]}
+	}
+	return limits, nil
+}
+
+// rlimitCases covers every field of garden.ResourceLimits. Values are below
+// the usual defaults, so that setting them never needs CAP_SYS_RESOURCE.
+// Nice and Rtprio are the exception: their default hard limit is 0, so any
+// value raises it, which takes CAP_SYS_RESOURCE in the initial user
+// namespace. Only privileged containers have that, so those rows are
+// privilegedOnly, and unprivileged containers check that they are refused.
+var rlimitCases = []struct {
+	field          string
+	row            string
+	value          uint64
+	privilegedOnly bool
+	set            func(*garden.ResourceLimits, uint64)
+}{
+	{"As", "Max address space", 1 << 30, false, func(l *garden.ResourceLimits, v uint64) { l.As = &v }},
+	{"Core", "Max core file size", 4096, false, func(l *garden.ResourceLimits, v uint64) { l.Core = &v }},
+	{"Cpu", "Max cpu time", 3600, false, func(l *garden.ResourceLimits, v uint64) { l.Cpu = &v }},
+	{"Data", "Max data size", 512 << 20, false, func(l *garden.ResourceLimits, v uint64) { l.Data = &v }},
+	{"Fsize", "Max file size", 1 << 30, false, func(l *garden.ResourceLimits, v uint64) { l.Fsize = &v }},
+	{"Locks", "Max file locks", 1000, false, func(l *garden.ResourceLimits, v uint64) { l.Locks = &v }},
+	{"Memlock", "Max locked memory", 32768, false, func(l *garden.ResourceLimits, v uint64) { l.Memlock = &v }},
+	{"Msgqueue", "Max msgqueue size", 409600, false, func(l *garden.ResourceLimits, v uint64) { l.Msgqueue = &v }},
+	{"Nice", "Max nice priority", 5, true, func(l *garden.ResourceLimits, v uint64) { l.Nice = &v }},
+	{"Nofile", "Max open files", 1234, false, func(l *garden.ResourceLimits, v uint64) { l.Nofile = &v }},
+	{"Nproc", "Max processes", 1000, false, func(l *garden.ResourceLimits, v uint64) { l.Nproc = &v }},
+	{"Rss", "Max resident set", 256 << 20, false, func(l *garden.ResourceLimits, v uint64) { l.Rss = &v }},
+	{"Rtprio", "Max realtime priority", 1, true, func(l *garden.ResourceLimits, v uint64) { l.Rtprio = &v }},
+	{"Sigpending", "Max pending signals", 1000, false, func(l *garden.ResourceLimits, v uint64) { l.Sigpending = &v }},
+	{"Stack", "Max stack size", 4 << 20, false, func(l *garden.ResourceLimits, v uint64) { l.Stack = &v }},
+}
+
+var _ = Describe("process rlimits", func() {
+	for _, privileged := range []bool{false, true} {
+		privileged := privileged
+
+		kind := "unprivileged"
+		if privileged {
+			kind = "privileged"
+		}
+
+		Context("in "+kind+" containers", func() {
+			var container garden.Container
+
+			BeforeEach(func() {
+				if privileged {
+					requireCapabilities(privilegedContainers)
+				}
+				container = createContainer(gardenClient, garden.ContainerSpec{Privileged: privileged})
+			})
+
+			appliedLimits := func(limits garden.ResourceLimits) map[string]rlimit {
+				result := runIn(container).Limits(limits).Command("cat", "/proc/self/limits").Wait()
+				Ω(result).Should(ExitWith(0))
+				applied, err := parseProcLimits(string(result.Stdout.Contents()))
+				Ω(err).ShouldNot(HaveOccurred())
+				return applied
+			}
+
+			for _, c := range rlimitCases {
+				c := c
+				if c.privilegedOnly && !privileged {
+					// the kernel refuses to raise the hard limit, which
+					// Garden may report from Run, or as the process failing,
+					// or not at all; the limit must not be raised either way
+					It(fmt.Sprintf("cannot raise the hard %s", strings.ToLower(c.row)), func() {
+						var limits garden.ResourceLimits
+						c.set(&limits, c.value)
+
+						stdout := gbytes.NewBuffer()
+						process, err := container.Run(garden.ProcessSpec{
+							User:   "root",
+							Path:   "cat",
+							Args:   []string{"/proc/self/limits"},
+							Limits: limits,
+						}, recordedProcessIO(stdout))
+						if err != nil {
+							fmt.Fprintf(GinkgoWriter, "Run refused %s: %s\n", c.field, err)
+							return
+						}
+						status, err := process.Wait()
+						Ω(err).ShouldNot(HaveOccurred())
+						if status != 0 {
+							return
+						}
+
+						applied, err := parseProcLimits(string(stdout.Contents()))
+						Ω(err).ShouldNot(HaveOccurred())
+						Ω(applied).Should(HaveKeyWithValue(c.row, rlimit{Soft: "0", Hard: "0"}))
+					})
+					continue
+				}
+
+				It(fmt.Sprintf("applies %s as the soft and hard %s", c.field, strings.ToLower(c.row)), func() {
+					var limits garden.ResourceLimits
+					c.set(&limits, c.value)
+
+					expected := strconv.FormatUint(c.value, 10)
+					Ω(appliedLimits(limits)).Should(HaveKeyWithValue(c.row, rlimit{Soft: expected, Hard: expected}))
+				})
+			}
+
+			It("applies every limit at once", func() {
+				var limits garden.ResourceLimits
+				for _, c := range rlimitCases {
+					if c.privilegedOnly && !privileged {
+						continue
+					}
+					c.set(&limits, c.value)
+				}
+
+				applied := appliedLimits(limits)
+				for _, c := range rlimitCases {
+					if c.privilegedOnly && !privileged {
+						continue
+					}
+					expected := strconv.FormatUint(c.value, 10)
+					Ω(applied).Should(HaveKeyWithValue(c.row, rlimit{Soft: expected, Hard: expected}), c.field)
+				}
+			})
+		})
+	}
+})
@@ -0,0 +1,223 @@
+package garden_acceptance_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-acceptance/gardentest"
+	"gopkg.in/yaml.v2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/types"
+)
+
+// Every scenarios/*.yml file becomes a spec; the format is described in the
+// README.
+var _ = Describe("scenarios", func() {
+	for _, s := range loadScenarios("scenarios") {
+		s := s
+
+		if s.Pending {
+			PIt(s.Name)
+			continue
+		}
+
+		It(s.Name, func() {
+			s.run()
+		})
+	}
+})
+
+type scenario struct {
+	Name      string               `yaml:"name"`
+	Pending   bool                 `yaml:"pending"`
+	Container scenarioContainer    `yaml:"container"`
+	NetOut    []scenarioNetOut     `yaml:"net_out"`
+	Processes []scenarioProcess    `yaml:"processes"`
+	Expect    scenarioExpectations `yaml:"expect"`
+}
+
+type scenarioContainer struct {
+	RootFS     string            `yaml:"rootfs"`
+	Privileged bool              `yaml:"privileged"`
+	Network    string            `yaml:"network"`
+	Env        []string          `yaml:"env"`
+	Properties map[string]string `yaml:"properties"`
+	Limits     struct {
+		MemoryBytes uint64 `yaml:"memory_bytes"`
+		DiskBytes   uint64 `yaml:"disk_bytes"`
+		CPUShares   uint64 `yaml:"cpu_shares"`
+	} `yaml:"limits"`
+	BindMounts []struct {
+		Src       string `yaml:"src"`
+		Dst       string `yaml:"dst"`
+		ReadWrite bool   `yaml:"read_write"`
+	} `yaml:"bind_mounts"`
+}
+
+type scenarioNetOut struct {
+	Protocol string   `yaml:"protocol"`
+	Networks []string `yaml:"networks"`
+	Ports    []uint16 `yaml:"ports"`
+}
+
+type scenarioProcess struct {
+	User     string         `yaml:"user"`
+	Path     string         `yaml:"path"`
+	Args     []string       `yaml:"args"`
+	Env      []string       `yaml:"env"`
+	Dir      string         `yaml:"dir"`
+	ExitCode int            `yaml:"exit_code"`
+	Stdout   scenarioOutput `yaml:"stdout"`
+	Stderr   scenarioOutput `yaml:"stderr"`
+}
+
+type scenarioOutput struct {
+	Contains    []string `yaml:"contains"`
+	NotContains []string `yaml:"not_contains"`
+	Matches     string   `yaml:"matches"`
+}
+
+type scenarioExpectations struct {
+	ContainerIP string            `yaml:"container_ip"`
+	CPUShares   uint64            `yaml:"cpu_shares"`
+	MemoryBytes uint64            `yaml:"memory_bytes"`
+	Properties  map[string]string `yaml:"properties"`
+}
+
+// loadScenarios runs while the spec tree is built, so a malformed file stops
+// the suite before anything runs.
+func loadScenarios(dir string) []scenario {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yml"))
+	if err != nil {
+		panic(err)
+	}
+
+	var scenarios []scenario
+	for _, path := range paths {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+
+		var s scenario
+		if err := yaml.UnmarshalStrict(contents, &s); err != nil {
+			panic(fmt.Sprintf("invalid scenario %s: %s", path, err))
+		}
+		if s.Name == "" {
+			s.Name = strings.TrimSuffix(filepath.Base(path), ".yml")
+		}
+		s.Name = fmt.Sprintf("%s (%s)", s.Name, path)
+
+		scenarios = append(scenarios, s)
+	}
+	return scenarios
+}
+
+func (s scenario) run() {
+	if s.Container.Privileged {
+		requireCapabilities(privilegedContainers)
+	}
+	if strings.HasPrefix(s.Container.RootFS, "docker://") {
+		requireCapabilities(dockerRootFS)
+	}
+
+	container := createContainer(gardenClient, s.Container.spec())
+
+	for _, rule := range s.NetOut {
+		Ω(container.NetOut(rule.rule())).Should(Succeed())
+	}
+
+	for _, p := range s.Processes {
+		user := p.User
+		if user == "" {
+			user = "root"
+		}
+
+		result := runIn(container).As(user).Command(p.Path, p.Args...).Env(p.Env...).Dir(p.Dir).Wait()
+		Ω(result).Should(ExitWith(p.ExitCode))
+		p.Stdout.verify(result, HaveStdout)
+		p.Stderr.verify(result, HaveStderr)
+	}
+
+	if s.Expect.ContainerIP != "" {
+		Ω(container).Should(HaveContainerIP(s.Expect.ContainerIP))
+	}
+	for key, value := range s.Expect.Properties {
+		Ω(container).Should(HaveProperty(key, value))
+	}
+	if s.Expect.CPUShares != 0 {
+		limits, err := container.CurrentCPULimits()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(limits.LimitInShares).Should(Equal(s.Expect.CPUShares))
+	}
+	if s.Expect.MemoryBytes != 0 {
+		limits, err := container.CurrentMemoryLimits()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(limits.LimitInBytes).Should(Equal(s.Expect.MemoryBytes))
+	}
+}
+
+func (c scenarioContainer) spec() garden.ContainerSpec {
+	spec := garden.ContainerSpec{
+		RootFSPath: c.RootFS,
+		Privileged: c.Privileged,
+		Network:    c.Network,
+		Env:        c.Env,
+		Properties: c.Properties,
+		Limits: garden.Limits{
+			Memory: garden.MemoryLimits{LimitInBytes: c.Limits.MemoryBytes},
+			CPU:    garden.CPULimits{LimitInShares: c.Limits.CPUShares},
+			Disk:   garden.DiskLimits{ByteHard: c.Limits.DiskBytes, Scope: garden.DiskLimitScopeTotal},
+		},
+	}
+
+	for _, mount := range c.BindMounts {
+		mode := garden.BindMountModeRO
+		if mount.ReadWrite {
+			mode = garden.BindMountModeRW
+		}
+		spec.BindMounts = append(spec.BindMounts, garden.BindMount{SrcPath: mount.Src, DstPath: mount.Dst, Mode: mode})
+	}
+
+	return spec
+}
+
+func (n scenarioNetOut) rule() garden.NetOutRule {
+	protocols := map[string]garden.Protocol{
+		"":     garden.ProtocolAll,
+		"all":  garden.ProtocolAll,
+		"tcp":  garden.ProtocolTCP,
+		"udp":  garden.ProtocolUDP,
+		"icmp": garden.ProtocolICMP,
+	}
+	protocol, ok := protocols[strings.ToLower(n.Protocol)]
+	Ω(ok).Should(BeTrue(), fmt.Sprintf("Unknown protocol %q", n.Protocol))
+
+	rule := garden.NetOutRule{Protocol: protocol}
+	for _, network := range n.Networks {
+		ipRange, err := gardentest.ParseIPRange(network)
+		Ω(err).ShouldNot(HaveOccurred())
+		rule.Networks = append(rule.Networks, ipRange)
+	}
+	for _, port := range n.Ports {
+		rule.Ports = append(rule.Ports, garden.PortRangeFromPort(port))
+	}
+	return rule
+}
+
+func (o scenarioOutput) verify(result *processResult, have func(interface{}) types.GomegaMatcher) {
+	for _, substring := range o.Contains {
+		Ω(result).Should(have(ContainSubstring(substring)))
+	}
+	for _, substring := range o.NotContains {
+		Ω(result).ShouldNot(have(ContainSubstring(substring)))
+	}
+	if o.Matches != "" {
+		Ω(result).Should(have(MatchRegexp(o.Matches)))
+	}
+}
@@ -0,0 +1,82 @@
+package garden_acceptance_test
+
+import (
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type escapeAttempt struct {
+	Vector  string `json:"vector"`
+	Blocked bool   `json:"blocked"`
+	Detail  string `json:"detail"`
+}
+
+// escapeVectors are the vectors cmd/escapeprobe knows. Each gets its own spec
+// so that the suite reports which ones got through.
+var escapeVectors = []string{
+	"proc-sys-write",
+	"sys-write",
+	"mknod-block-device",
+	"mount",
+	"ptrace-host-process",
+	"load-kernel-module",
+	"sethostname",
+	"raw-socket",
+}
+
+var _ = Describe("container escapes from unprivileged containers", func() {
+	var container garden.Container
+	var hostProcess *exec.Cmd
+	var hostCmdline string
+
+	BeforeEach(func() {
+		container = createContainer(gardenClient, withProbes(garden.ContainerSpec{}))
+
+		// a process for the probe to try to ptrace; the suite runs on the
+		// Garden host, so this is a host process. Its command line is unique
+		// so that the probe cannot mistake a container process for it.
+		seconds := strconv.Itoa(1000000 + int(time.Now().UnixNano()%1000000))
+		hostCmdline = "sleep " + seconds
+		hostProcess = exec.Command("sleep", seconds)
+		Ω(hostProcess.Start()).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		hostProcess.Process.Kill()
+		hostProcess.Wait()
+	})
+
+	tryEscape := func(user, vector string) escapeAttempt {
+		var attempts []escapeAttempt
+		runProbeAs(user, container, &attempts, "escapeprobe", "-host-cmdline", hostCmdline, vector)
+		Ω(attempts).Should(HaveLen(1))
+		return attempts[0]
+	}
+
+	for _, user := range []string{"root", "alice"} {
+		user := user
+
+		Context("as "+user, func() {
+			for _, vector := range escapeVectors {
+				vector := vector
+
+				// root may open raw sockets, which ping needs, but only in
+				// the container's own network namespace
+				if user == "root" && vector == "raw-socket" {
+					continue
+				}
+
+				It("blocks "+vector, func() {
+					attempt := tryEscape(user, vector)
+					Ω(attempt.Blocked).Should(BeTrue(), "%s escaped as %s: %s", vector, user, attempt.Detail)
+				})
+			}
+		})
+	}
+})
@@ -0,0 +1,171 @@
+package garden_acceptance_test
+
+import (
+	"fmt"
+
+	"github.com/cloudfoundry-incubator/garden"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+// Exit codes from process.Wait(), which supervisors rely on:
+//
+//	exits with N                        N
+//	dies from any signal                255
+//	traps a signal and exits with N     N
+//	ignores TERM, then gets KILL        255
+//
+// A process that exits while a signal is on its way reports whichever
+// happened first, and Wait keeps returning that code.
+const signalledExitCode = 255
+
+var _ = Describe("signal delivery", func() {
+	var container garden.Container
+
+	BeforeEach(func() {
+		container = createContainer(gardenClient, garden.ContainerSpec{})
+	})
+
+	// start runs script, which must echo ready once its traps are set.
+	start := func(script string) (garden.Process, *processResult) {
+		process, result := runIn(container).Shell(script).Start()
+		Eventually(result.Stdout, "5s").Should(gbytes.Say("ready"))
+		return process, result
+	}
+
+	// sleepers counts the "sleep 1000" processes; the bracket stops grep
+	// counting itself, and the $ the shell that started them.
+	sleepers := func() string {
+		result := runIn(container).Shell("ps | grep -c '[s]leep 1000$'").Wait()
+		return string(result.Stdout.Contents())
+	}
+
+	Describe("through the API", func() {
+		signals := []struct {
+			name   string
+			signal garden.Signal
+		}{
+			{"TERM", garden.SignalTerminate},
+			{"KILL", garden.SignalKill},
+		}
+
+		for _, s := range signals {
+			s := s
+
+			Context("sending "+s.name, func() {
+				It("reports 255 for a process that dies from it", func() {
+					process, _ := start("echo ready; while true; do sleep 1; done")
+					Ω(process.Signal(s.signal)).Should(Succeed())
+					Ω(process.Wait()).Should(Equal(signalledExitCode))
+				})
+
+				It("reports the exit code of a process that traps it and exits", func() {
+					process, _ := start(fmt.Sprintf("trap 'exit 3' %s 2>/dev/null; echo ready; while true; do sleep 1; done", s.name))
+					Ω(process.Signal(s.signal)).Should(Succeed())
+
+					if s.signal == garden.SignalKill {
+						// KILL cannot be trapped
+						Ω(process.Wait()).Should(Equal(signalledExitCode))
+					} else {
+						Ω(process.Wait()).Should(Equal(3))
+					}
+				})
+			})
+		}
+
+		It("leaves a process that ignores TERM running until it gets KILL", func() {
+			process, result := start("trap '' TERM; echo ready; while true; do echo waiting; sleep 1; done")
+			Ω(process.Signal(garden.SignalTerminate)).Should(Succeed())
+
+			Eventually(result.Stdout, "3s").Should(gbytes.Say("waiting"))
+			Eventually(result.Stdout, "3s").Should(gbytes.Say("waiting"), "Process stopped after TERM")
+
+			Ω(process.Signal(garden.SignalKill)).Should(Succeed())
+			Ω(process.Wait()).Should(Equal(signalledExitCode))
+		})
+
+		It("returns from Wait when a process is killed while its children still run", func() {
+			process, _ := start("sleep 1000 & sleep 1000 & echo ready; wait")
+			Ω(process.Signal(garden.SignalKill)).Should(Succeed())
+
+			exitCode := make(chan int, 1)
+			go func() {
+				defer GinkgoRecover()
+				code, err := process.Wait()
+				Ω(err).ShouldNot(HaveOccurred())
+				exitCode <- code
+			}()
+			Eventually(exitCode, "5s").Should(Receive(Equal(signalledExitCode)))
+		})
+
+		It("lets a process forward TERM to its process group", func() {
+			process, _ := start("trap 'kill -TERM 0; exit 4' TERM; sleep 1000 & sleep 1000 & echo ready; wait")
+			Ω(sleepers()).Should(Equal("2\n"))
+
+			Ω(process.Signal(garden.SignalTerminate)).Should(Succeed())
+			Ω(process.Wait()).Should(Equal(4))
+			Eventually(sleepers, "5s").Should(Equal("0\n"))
+		})
+	})
+
+	Describe("from inside the container", func() {
+		for _, name := range []string{"HUP", "INT", "QUIT", "USR1", "USR2", "ALRM", "PIPE", "TERM"} {
+			name := name
+
+			Context("sending "+name, func() {
+				It("reports 255 for a process that dies from it", func() {
+					Ω(runIn(container).Shell(fmt.Sprintf("kill -%s $$; sleep 5; exit 1", name)).Wait()).Should(ExitWith(signalledExitCode))
+				})
+
+				It("reports the exit code of a process that ignores it", func() {
+					Ω(runIn(container).Shell(fmt.Sprintf("trap '' %[1]s; kill -%[1]s $$; exit 7", name)).Wait()).Should(ExitWith(7))
+				})
+
+				It("reports the exit code of a process that traps it and exits", func() {
+					Ω(runIn(container).Shell(fmt.Sprintf("trap 'exit 9' %[1]s; kill -%[1]s $$; sleep 5; exit 1", name)).Wait()).Should(ExitWith(9))
+				})
+			})
+		}
+	})
+
+	Describe("zombies", func() {
+		defunct := func() []string {
+			return defunctPIDs(container)
+		}
+
+		It("reports the exit code of a process that leaves an unreaped child", func() {
+			// exec replaces the shell, so nothing waits for the first sleep
+			Ω(runIn(container).Shell("sleep 0.1 & exec sh -c 'sleep 1; exit 6'").Wait()).Should(ExitWith(6))
+		})
+
+		It("reports 255 for a killed process with a zombie child, and the zombie is reaped", func() {
+			process, _ := start("sleep 0.1 & echo ready; exec sleep 1000")
+			Eventually(defunct, "3s").Should(HaveLen(1))
+
+			Ω(process.Signal(garden.SignalKill)).Should(Succeed())
+			Ω(process.Wait()).Should(Equal(signalledExitCode))
+			Eventually(defunct, "5s").Should(BeEmpty())
+		})
+	})
+
+	Describe("processes that exit during delivery", func() {
+		It("reports whichever of the exit and the signal came first", func() {
+			for i := 0; i < 50; i++ {
+				process, _ := runIn(container).Shell("exit 5").Start()
+				process.Signal(garden.SignalTerminate) // may fail if the process has gone
+
+				Ω(process.Wait()).Should(Or(Equal(5), Equal(signalledExitCode)))
+			}
+		})
+
+		It("keeps reporting the exit code when signalled after exiting", func() {
+			process, _ := runIn(container).Shell("exit 5").Start()
+			Ω(process.Wait()).Should(Equal(5))
+
+			process.Signal(garden.SignalKill)
+			Ω(process.Wait()).Should(Equal(5))
+		})
+	})
+})
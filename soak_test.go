@@ -0,0 +1,315 @@
+package garden_acceptance_test
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-acceptance/gardentest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+// The soak spec only runs when GARDEN_ACCEPTANCE_SOAK_DURATION is set, e.g.
+//
+//	GARDEN_ACCEPTANCE_SOAK_DURATION=30m ginkgo -focus=soak
+//
+// GARDEN_ACCEPTANCE_SOAK_SEED replays a previous run; it defaults to the
+// ginkgo random seed.
+var _ = Describe("soak", func() {
+	BeforeEach(func() {
+		if os.Getenv("GARDEN_ACCEPTANCE_SOAK_DURATION") == "" {
+			Skip("set GARDEN_ACCEPTANCE_SOAK_DURATION to run the soak")
+		}
+		requireCapabilities(containerPathOnHost)
+	})
+
+	It("survives a random mixed workload", func() {
+		duration, err := time.ParseDuration(os.Getenv("GARDEN_ACCEPTANCE_SOAK_DURATION"))
+		Ω(err).ShouldNot(HaveOccurred())
+		seed := int64(envInt("GARDEN_ACCEPTANCE_SOAK_SEED", int(GinkgoRandomSeed())))
+
+		driver := &soakDriver{
+			random:       rand.New(rand.NewSource(seed)),
+			seed:         seed,
+			portPoolSize: envInt("GARDEN_ACCEPTANCE_PORT_POOL_SIZE", 5),
+			containers:   map[string]*soakContainer{},
+		}
+
+		deadline := time.Now().Add(duration)
+		for i := 0; time.Now().Before(deadline); i++ {
+			driver.step()
+			if i%10 == 9 {
+				driver.checkInvariants()
+			}
+		}
+
+		driver.checkInvariants()
+		driver.checkPortsReleased()
+	})
+})
+
+type soakContainer struct {
+	container  garden.Container
+	properties garden.Properties
+	ports      []uint32
+}
+
+type soakDriver struct {
+	random       *rand.Rand
+	seed         int64
+	portPoolSize int
+
+	containers map[string]*soakContainer
+	portsInUse int
+	created    int
+	history    []string
+}
+
+func (d *soakDriver) step() {
+	if len(d.containers) == 0 {
+		d.create()
+		return
+	}
+
+	switch d.random.Intn(10) {
+	case 0:
+		if len(d.containers) < 8 {
+			d.create()
+		} else {
+			d.destroy()
+		}
+	case 1:
+		d.destroy()
+	case 2:
+		d.runToCompletion()
+	case 3:
+		d.runAndSignal()
+	case 4:
+		d.netIn()
+	case 5:
+		d.netOut()
+	case 6, 7:
+		d.setProperty()
+	case 8:
+		d.removeProperty()
+	case 9:
+		d.checkProperties()
+	}
+}
+
+func (d *soakDriver) record(format string, args ...interface{}) {
+	d.history = append(d.history, fmt.Sprintf(format, args...))
+}
+
+// expect fails with the seed and the most recent operations, which is
+// usually enough to replay and reason about the failure.
+func (d *soakDriver) expect(ok bool, format string, args ...interface{}) {
+	if ok {
+		return
+	}
+
+	recent := d.history
+	if len(recent) > 20 {
+		recent = recent[len(recent)-20:]
+	}
+	Fail(fmt.Sprintf("soak (seed %d, %d ops): %s\n\nrecent operations:\n  %s",
+		d.seed, len(d.history), fmt.Sprintf(format, args...), strings.Join(recent, "\n  ")))
+}
+
+func (d *soakDriver) expectNoError(err error, action string) {
+	d.expect(err == nil, "%s: %v", action, err)
+}
+
+func (d *soakDriver) pick() *soakContainer {
+	var handles []string
+	for handle := range d.containers {
+		handles = append(handles, handle)
+	}
+	// map order is random, so sort for the seed to be reproducible
+	sort.Strings(handles)
+	return d.containers[handles[d.random.Intn(len(handles))]]
+}
+
+func (d *soakDriver) create() {
+	d.created++
+	handle := fmt.Sprintf("soak-%d-%d", d.seed, d.created)
+	d.record("create %s", handle)
+
+	container, err := gardenClient.Create(garden.ContainerSpec{Handle: handle, Properties: garden.Properties{"soak": "true"}})
+	d.expectNoError(err, "create "+handle)
+	d.containers[handle] = &soakContainer{container: container, properties: garden.Properties{"soak": "true"}}
+}
+
+func (d *soakDriver) destroy() {
+	c := d.pick()
+	d.record("destroy %s", c.container.Handle())
+
+	d.expectNoError(gardenClient.Destroy(c.container.Handle()), "destroy "+c.container.Handle())
+	d.portsInUse -= len(c.ports)
+	delete(d.containers, c.container.Handle())
+}
+
+func (d *soakDriver) runToCompletion() {
+	c := d.pick()
+	token := fmt.Sprintf("token-%d", d.random.Int63())
+	d.record("run echo %s in %s", token, c.container.Handle())
+
+	buffer := gbytes.NewBuffer()
+	process, err := c.container.Run(garden.ProcessSpec{User: "root", Path: "echo", Args: []string{token}}, recordedProcessIO(buffer))
+	d.expectNoError(err, "run")
+	status, err := process.Wait()
+	d.expectNoError(err, "wait")
+	d.expect(status == 0, "echo exited with %d", status)
+	d.expect(strings.Contains(string(buffer.Contents()), token), "echo printed %q", buffer.Contents())
+}
+
+func (d *soakDriver) runAndSignal() {
+	c := d.pick()
+	signal, name := garden.SignalKill, "KILL"
+	if d.random.Intn(2) == 0 {
+		signal, name = garden.SignalTerminate, "TERM"
+	}
+	d.record("run sleep and send %s in %s", name, c.container.Handle())
+
+	process, err := c.container.Run(garden.ProcessSpec{User: "root", Path: "sleep", Args: []string{"1000"}}, silentProcessIO)
+	d.expectNoError(err, "run")
+	d.expectNoError(process.Signal(signal), "signal "+name)
+
+	exited := make(chan int, 1)
+	go func() {
+		status, _ := process.Wait()
+		exited <- status
+	}()
+
+	select {
+	case status := <-exited:
+		d.expect(status != 0, "sleep exited 0 after %s", name)
+	case <-time.After(10 * time.Second):
+		d.expect(false, "sleep did not exit within 10s of %s", name)
+	}
+}
+
+func (d *soakDriver) netIn() {
+	c := d.pick()
+	d.record("netin in %s (%d/%d ports in use)", c.container.Handle(), d.portsInUse, d.portPoolSize)
+
+	hostPort, _, err := c.container.NetIn(0, 0)
+	if d.portsInUse >= d.portPoolSize {
+		d.expect(err != nil, "netin succeeded with port %d although the pool should be exhausted", hostPort)
+		return
+	}
+	d.expectNoError(err, "netin")
+
+	for _, other := range d.containers {
+		for _, port := range other.ports {
+			d.expect(port != hostPort, "port %d given to %s is still held by %s", hostPort, c.container.Handle(), other.container.Handle())
+		}
+	}
+	c.ports = append(c.ports, hostPort)
+	d.portsInUse++
+}
+
+func (d *soakDriver) netOut() {
+	c := d.pick()
+	ip := fmt.Sprintf("10.%d.%d.%d", d.random.Intn(256), d.random.Intn(256), d.random.Intn(256))
+	d.record("netout icmp %s in %s", ip, c.container.Handle())
+
+	d.expectNoError(c.container.NetOut(gardentest.PingRule(ip)), "netout")
+}
+
+func (d *soakDriver) setProperty() {
+	c := d.pick()
+	key := fmt.Sprintf("key-%d", d.random.Intn(4))
+	value := fmt.Sprintf("value-%d", d.random.Intn(4))
+	d.record("set %s=%s in %s", key, value, c.container.Handle())
+
+	d.expectNoError(c.container.SetProperty(key, value), "set property")
+	c.properties[key] = value
+}
+
+func (d *soakDriver) removeProperty() {
+	c := d.pick()
+	key := fmt.Sprintf("key-%d", d.random.Intn(4))
+	d.record("remove %s in %s", key, c.container.Handle())
+
+	err := c.container.RemoveProperty(key)
+	if _, ok := c.properties[key]; ok {
+		d.expectNoError(err, "remove property")
+		delete(c.properties, key)
+	} else {
+		d.expect(err != nil, "removing missing property %s succeeded", key)
+	}
+}
+
+func (d *soakDriver) checkProperties() {
+	c := d.pick()
+	d.record("check properties of %s", c.container.Handle())
+
+	properties, err := c.container.Properties()
+	d.expectNoError(err, "properties")
+	d.expect(reflect.DeepEqual(properties, c.properties), "properties are %v, expected %v", properties, c.properties)
+
+	for key, value := range c.properties {
+		filtered, err := gardenClient.Containers(garden.Properties{key: value})
+		d.expectNoError(err, "containers")
+
+		var found bool
+		for _, container := range filtered {
+			found = found || container.Handle() == c.container.Handle()
+		}
+		d.expect(found, "filtering by %s=%s did not return %s", key, value, c.container.Handle())
+	}
+}
+
+func (d *soakDriver) checkInvariants() {
+	containers, err := gardenClient.Containers(nil)
+	d.expectNoError(err, "containers")
+
+	var actual, expected []string
+	for _, container := range containers {
+		actual = append(actual, container.Handle())
+	}
+	for handle := range d.containers {
+		expected = append(expected, handle)
+	}
+	sort.Strings(actual)
+	sort.Strings(expected)
+	d.expect(strings.Join(actual, ",") == strings.Join(expected, ","), "backend has containers %v, expected %v", actual, expected)
+
+	for _, c := range d.containers {
+		info, err := c.container.Info()
+		d.expectNoError(err, "info")
+
+		var stderr string
+		for attempt := 0; attempt < 10; attempt++ {
+			_, stderr, _ = gardentest.RunCommand("cd " + info.ContainerPath + "/processes && ls *.sock")
+			if strings.Contains(stderr, "No such file or directory") {
+				break
+			}
+			time.Sleep(time.Second)
+		}
+		d.expect(strings.Contains(stderr, "No such file or directory"), "%s has orphaned sockets", c.container.Handle())
+	}
+}
+
+// checkPortsReleased destroys everything and checks that the whole port pool
+// can be handed out again.
+func (d *soakDriver) checkPortsReleased() {
+	for len(d.containers) > 0 {
+		d.destroy()
+	}
+
+	container := createContainer(gardenClient, garden.ContainerSpec{})
+	for i := 0; i < d.portPoolSize; i++ {
+		_, _, err := container.NetIn(0, 0)
+		d.expectNoError(err, fmt.Sprintf("netin %d of %d after destroying everything", i+1, d.portPoolSize))
+	}
+}
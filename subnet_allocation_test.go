@@ -0,0 +1,328 @@
+package garden_acceptance_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("subnet allocation", func() {
+	const scenarios = 5
+	const opsPerScenario = 12
+
+	It("maintains allocator invariants across random sequences of networks", func() {
+		for i := 0; i < scenarios; i++ {
+			seed := GinkgoRandomSeed() + int64(i)
+			ops := generateSubnetOps(rand.New(rand.NewSource(seed)), opsPerScenario)
+
+			err := runSubnetScenario(ops)
+			if err == nil {
+				continue
+			}
+
+			minimal, minimalErr := shrinkSubnetScenario(ops, err)
+			Fail(fmt.Sprintf(
+				"subnet allocation invariant violated (seed %d)\n\nminimal reproduction:\n%s\nerror: %s",
+				seed, describeSubnetOps(minimal), minimalErr,
+			))
+		}
+	})
+})
+
+type subnetOp struct {
+	// network is the ContainerSpec.Network for a create; empty for a destroy
+	// or for a create from the default pool when destroy is false.
+	network string
+	destroy bool
+	// target is the index of the create op whose container is destroyed.
+	target int
+}
+
+func (op subnetOp) String() string {
+	if op.destroy {
+		return fmt.Sprintf("destroy #%d", op.target)
+	}
+	if op.network == "" {
+		return "create (default pool)"
+	}
+	return fmt.Sprintf("create %s", op.network)
+}
+
+func describeSubnetOps(ops []subnetOp) string {
+	var lines []string
+	for i, op := range ops {
+		lines = append(lines, fmt.Sprintf("  #%d: %s", i, op))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// generateSubnetOps picks networks from a handful of nested ranges in
+// 10.2.0.0/16 so that identical, overlapping and disjoint subnets all turn
+// up regularly.
+func generateSubnetOps(r *rand.Rand, n int) []subnetOp {
+	parents := []string{"10.2.0.0", "10.2.1.0", "10.2.4.0"}
+	prefixes := []int{24, 28, 30}
+
+	var ops []subnetOp
+	for len(ops) < n {
+		var creates []int
+		for i, op := range ops {
+			if !op.destroy {
+				creates = append(creates, i)
+			}
+		}
+
+		switch roll := r.Intn(10); {
+		case roll < 2 && len(creates) > 0:
+			ops = append(ops, subnetOp{destroy: true, target: creates[r.Intn(len(creates))]})
+		case roll < 3:
+			ops = append(ops, subnetOp{})
+		default:
+			prefix := prefixes[r.Intn(len(prefixes))]
+			_, subnet, _ := net.ParseCIDR(fmt.Sprintf("%s/%d", parents[r.Intn(len(parents))], prefix))
+			ip := subnet.IP
+			if r.Intn(2) == 0 {
+				first, last := subnetHostRange(subnet)
+				ip = uint32ToIP(first + uint32(r.Intn(int(last-first+1))))
+			}
+			ops = append(ops, subnetOp{network: fmt.Sprintf("%s/%d", ip, prefix)})
+		}
+	}
+	return ops
+}
+
+// shrinkSubnetScenario repeatedly drops single ops while the scenario keeps
+// failing, returning the smallest failing sequence it finds.
+func shrinkSubnetScenario(ops []subnetOp, err error) ([]subnetOp, error) {
+	for shrunk := true; shrunk; {
+		shrunk = false
+		for i := len(ops) - 1; i >= 0; i-- {
+			candidate := withoutSubnetOp(ops, i)
+			if candidateErr := runSubnetScenario(candidate); candidateErr != nil {
+				ops, err, shrunk = candidate, candidateErr, true
+			}
+		}
+	}
+	return ops, err
+}
+
+// withoutSubnetOp removes the op at index i, dropping destroys of the
+// removed create and renumbering the targets of the remaining ones.
+func withoutSubnetOp(ops []subnetOp, i int) []subnetOp {
+	var result []subnetOp
+	renumbered := map[int]int{}
+	for j, op := range ops {
+		if j == i || (op.destroy && op.target == i) {
+			continue
+		}
+		if op.destroy {
+			op.target = renumbered[op.target]
+		}
+		renumbered[j] = len(result)
+		result = append(result, op)
+	}
+	return result
+}
+
+type modelSubnet struct {
+	subnet    *net.IPNet
+	allocated map[uint32]int
+}
+
+type subnetModel struct {
+	subnets map[string]*modelSubnet
+	owners  map[int]*modelSubnet
+}
+
+// predict returns the IP the allocator should hand out for network, or the
+// error messages it may legitimately fail with. A nil error slice with a nil
+// IP means any error is acceptable.
+func (m *subnetModel) predict(network string) (net.IP, []string) {
+	ip, subnet, err := net.ParseCIDR(network)
+	Ω(err).ShouldNot(HaveOccurred())
+
+	if existing, ok := m.subnets[subnet.String()]; ok {
+		if !ip.Equal(subnet.IP) {
+			if _, taken := existing.allocated[ipToUint32(ip)]; taken {
+				return nil, nil
+			}
+			return ip, nil
+		}
+
+		first, last := subnetHostRange(subnet)
+		for candidate := first; candidate <= last; candidate++ {
+			if _, taken := existing.allocated[candidate]; !taken {
+				return uint32ToIP(candidate), nil
+			}
+		}
+		return nil, nil
+	}
+
+	var messages []string
+	for _, existing := range m.subnets {
+		if existing.subnet.Contains(subnet.IP) || subnet.Contains(existing.subnet.IP) {
+			messages = append(messages, fmt.Sprintf(
+				"the requested subnet (%s) overlaps an existing subnet (%s)", subnet, existing.subnet,
+			))
+		}
+	}
+	if len(messages) > 0 {
+		return nil, messages
+	}
+
+	if ip.Equal(subnet.IP) {
+		first, _ := subnetHostRange(subnet)
+		return uint32ToIP(first), nil
+	}
+	return ip, nil
+}
+
+func (m *subnetModel) allocate(owner int, network string, ip net.IP) {
+	_, subnet, _ := net.ParseCIDR(network)
+	existing, ok := m.subnets[subnet.String()]
+	if !ok {
+		existing = &modelSubnet{subnet: subnet, allocated: map[uint32]int{}}
+		m.subnets[subnet.String()] = existing
+	}
+	existing.allocated[ipToUint32(ip)] = owner
+	m.owners[owner] = existing
+}
+
+func (m *subnetModel) release(owner int) {
+	existing, ok := m.owners[owner]
+	if !ok {
+		return
+	}
+	for ip, o := range existing.allocated {
+		if o == owner {
+			delete(existing.allocated, ip)
+		}
+	}
+	if len(existing.allocated) == 0 {
+		delete(m.subnets, existing.subnet.String())
+	}
+	delete(m.owners, owner)
+}
+
+// runSubnetScenario plays ops against a clean backend, returning the first
+// divergence from the model rather than failing, so that callers can shrink.
+func runSubnetScenario(ops []subnetOp) error {
+	destroyAllContainers(gardenClient)
+	defer destroyAllContainers(gardenClient)
+
+	model := &subnetModel{subnets: map[string]*modelSubnet{}, owners: map[int]*modelSubnet{}}
+	containers := map[int]garden.Container{}
+	ips := map[string]int{}
+
+	for i, op := range ops {
+		if op.destroy {
+			container, ok := containers[op.target]
+			if !ok {
+				continue
+			}
+			if err := gardenClient.Destroy(container.Handle()); err != nil {
+				return fmt.Errorf("#%d: %s: %s", i, op, err)
+			}
+			for ip, owner := range ips {
+				if owner == op.target {
+					delete(ips, ip)
+				}
+			}
+			model.release(op.target)
+			delete(containers, op.target)
+			continue
+		}
+
+		var expectedIP net.IP
+		var expectedErrors []string
+		if op.network != "" {
+			expectedIP, expectedErrors = model.predict(op.network)
+		}
+
+		container, err := gardenClient.Create(withProbes(garden.ContainerSpec{Network: op.network}))
+		if err != nil {
+			if op.network == "" || expectedIP != nil {
+				return fmt.Errorf("#%d: %s: unexpected error: %s", i, op, err)
+			}
+			if expectedErrors != nil && !containsString(expectedErrors, err.Error()) {
+				return fmt.Errorf("#%d: %s: expected one of %q, got %q", i, op, expectedErrors, err)
+			}
+			if _, retryErr := gardenClient.Create(withProbes(garden.ContainerSpec{Network: op.network})); retryErr == nil || retryErr.Error() != err.Error() {
+				return fmt.Errorf("#%d: %s: error is not deterministic: %q then %v", i, op, err, retryErr)
+			}
+			continue
+		}
+		containers[i] = container
+
+		if op.network != "" && expectedIP == nil {
+			return fmt.Errorf("#%d: %s: expected an error, but created %s", i, op, container.Handle())
+		}
+
+		info, err := container.Info()
+		if err != nil {
+			return fmt.Errorf("#%d: %s: info: %s", i, op, err)
+		}
+		if owner, taken := ips[info.ContainerIP]; taken {
+			return fmt.Errorf("#%d: %s: ip %s already belongs to #%d", i, op, info.ContainerIP, owner)
+		}
+		ips[info.ContainerIP] = i
+
+		if op.network == "" {
+			continue
+		}
+		if info.ContainerIP != expectedIP.String() {
+			return fmt.Errorf("#%d: %s: expected ip %s, got %s", i, op, expectedIP, info.ContainerIP)
+		}
+		model.allocate(i, op.network, expectedIP)
+
+		_, subnet, _ := net.ParseCIDR(op.network)
+		netInfo, err := probeNetInfo(container)
+		if err != nil {
+			return fmt.Errorf("#%d: %s: netinfo: %s", i, op, err)
+		}
+		gateway, ok := netInfo.DefaultGateway()
+		if !ok {
+			return fmt.Errorf("#%d: %s: no default gateway", i, op)
+		}
+		if expected := uint32ToIP(ipToUint32(subnet.IP) + 1).String(); gateway != expected {
+			return fmt.Errorf("#%d: %s: expected gateway %s, got %s", i, op, expected, gateway)
+		}
+	}
+
+	return nil
+}
+
+// subnetHostRange returns the first and last addresses that can be given to
+// containers, skipping the network, gateway and broadcast addresses.
+func subnetHostRange(subnet *net.IPNet) (uint32, uint32) {
+	ones, bits := subnet.Mask.Size()
+	network := ipToUint32(subnet.IP)
+	broadcast := network | (1<<uint(bits-ones) - 1)
+	return network + 2, broadcast - 1
+}
+
+func ipToUint32(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+func uint32ToIP(n uint32) net.IP {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
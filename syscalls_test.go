@@ -0,0 +1,92 @@
+package garden_acceptance_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cloudfoundry-incubator/garden"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// syscallBaselinesDir holds what cmd/syscallprobe reported against the
+// reference backend, one file per container kind. Setting
+// GARDEN_ACCEPTANCE_UPDATE_SYSCALL_BASELINE rewrites them from the current
+// backend instead of comparing, so that a policy change lands as a diff.
+const syscallBaselinesDir = "baselines/syscalls"
+
+var _ = Describe("syscall filtering", func() {
+	for _, privileged := range []bool{false, true} {
+		privileged := privileged
+
+		kind := "unprivileged"
+		if privileged {
+			kind = "privileged"
+		}
+
+		It("matches the baseline for "+kind+" containers", func() {
+			if privileged {
+				requireCapabilities(privilegedContainers)
+			}
+
+			container := createContainer(gardenClient, withProbes(garden.ContainerSpec{Privileged: privileged}))
+			var results map[string]string
+			runProbe(container, &results, "syscallprobe")
+
+			path := filepath.Join(syscallBaselinesDir, kind+".json")
+			if os.Getenv("GARDEN_ACCEPTANCE_UPDATE_SYSCALL_BASELINE") != "" {
+				encoded, err := json.MarshalIndent(results, "", "  ")
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(os.MkdirAll(syscallBaselinesDir, 0755)).Should(Succeed())
+				Ω(ioutil.WriteFile(path, append(encoded, '\n'), 0644)).Should(Succeed())
+				fmt.Fprintf(GinkgoWriter, "Updated %s\n", path)
+				return
+			}
+
+			encoded, err := ioutil.ReadFile(path)
+			if os.IsNotExist(err) {
+				Fail(fmt.Sprintf("No baseline at %s; record one against the reference backend with GARDEN_ACCEPTANCE_UPDATE_SYSCALL_BASELINE=1", path))
+			}
+			Ω(err).ShouldNot(HaveOccurred())
+			var baseline map[string]string
+			Ω(json.Unmarshal(encoded, &baseline)).Should(Succeed())
+
+			Ω(diffSyscallResults(baseline, results)).Should(BeEmpty(),
+				"Syscall policy for %s containers differs from %s; if that is intended, rerun with GARDEN_ACCEPTANCE_UPDATE_SYSCALL_BASELINE=1", kind, path)
+		})
+	}
+})
+
+// diffSyscallResults lists, by syscall, where results differ from baseline.
+func diffSyscallResults(baseline, results map[string]string) []string {
+	var names []string
+	for name := range baseline {
+		names = append(names, name)
+	}
+	for name := range results {
+		if _, ok := baseline[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var diff []string
+	for _, name := range names {
+		was, inBaseline := baseline[name]
+		now, inResults := results[name]
+		switch {
+		case !inBaseline:
+			diff = append(diff, fmt.Sprintf("%s: not in baseline, now %s", name, now))
+		case !inResults:
+			diff = append(diff, fmt.Sprintf("%s: %s in baseline, not probed", name, was))
+		case was != now:
+			diff = append(diff, fmt.Sprintf("%s: %s in baseline, now %s", name, was, now))
+		}
+	}
+	return diff
+}
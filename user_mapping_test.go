@@ -5,44 +5,22 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	"github.com/onsi/gomega/gbytes"
 )
 
 var _ = Describe("user mapping", func() {
 	validatePermissions := func(rootFSPath string) {
 		container := createContainer(gardenClient, garden.ContainerSpec{RootFSPath: rootFSPath})
 
-		buffer := gbytes.NewBuffer()
-		process, err := container.Run(
-			garden.ProcessSpec{User: "bob", Path: "touch", Args: []string{"/home/alice/not_me"}},
-			recordedProcessIO(buffer),
-		)
-		Ω(err).ShouldNot(HaveOccurred())
-		Ω(process.Wait()).ShouldNot(Equal(0))
-		Ω(buffer).Should(gbytes.Say("touch: /home/alice/not_me: Permission denied"))
-
-		process, err = container.Run(
-			garden.ProcessSpec{User: "alice", Path: "touch", Args: []string{"/home/alice/me"}},
-			silentProcessIO,
-		)
-		Ω(err).ShouldNot(HaveOccurred())
-		Ω(process.Wait()).Should(Equal(0))
-
-		process, err = container.Run(
-			garden.ProcessSpec{User: "root", Path: "touch", Args: []string{"/etc/i_am_root"}},
-			silentProcessIO,
-		)
-		Ω(err).ShouldNot(HaveOccurred())
-		Ω(process.Wait()).Should(Equal(0))
-
-		buffer = gbytes.NewBuffer()
-		process, err = container.Run(
-			garden.ProcessSpec{User: "alice", Path: "touch", Args: []string{"/etc/i_am_not_root"}},
-			recordedProcessIO(buffer),
-		)
-		Ω(err).ShouldNot(HaveOccurred())
-		Ω(process.Wait()).ShouldNot(Equal(0))
-		Ω(buffer).Should(gbytes.Say("touch: /etc/i_am_not_root: Permission denied"))
+		result := runIn(container).As("bob").Command("touch", "/home/alice/not_me").Wait()
+		Ω(result).ShouldNot(ExitWith(0))
+		Ω(result).Should(HaveStderr(ContainSubstring("touch: /home/alice/not_me: Permission denied")))
+
+		Ω(runIn(container).As("alice").Command("touch", "/home/alice/me").Wait()).Should(ExitWith(0))
+		Ω(runIn(container).As("root").Command("touch", "/etc/i_am_root").Wait()).Should(ExitWith(0))
+
+		result = runIn(container).As("alice").Command("touch", "/etc/i_am_not_root").Wait()
+		Ω(result).ShouldNot(ExitWith(0))
+		Ω(result).Should(HaveStderr(ContainSubstring("touch: /etc/i_am_not_root: Permission denied")))
 	}
 
 	It("maintains permissions from a garden directory rootfs (#92808274)", func() {
@@ -50,6 +28,7 @@ var _ = Describe("user mapping", func() {
 	})
 
 	It("maintains permissions from docker images (#91955652)", func() {
+		requireCapabilities(dockerRootFS)
 		validatePermissions("docker:///cloudfoundry/garden-pm#alice")
 	})
 })